@@ -0,0 +1,195 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// AggregateConfig sets the top-level fields of the aggregate package.json.
+type AggregateConfig struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Description string            `json:"description"`
+	Private     *bool             `json:"private"`
+	Engines     map[string]string `json:"engines"`
+}
+
+// aggregateManifest is the layout of the aggregate package.json. Fields are
+// written in declaration order and map keys sorted, so the same inventory
+// always produces the same bytes.
+type aggregateManifest struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Description     string            `json:"description,omitempty"`
+	Private         bool              `json:"private"`
+	Engines         map[string]string `json:"engines,omitempty"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+// RenderAggregate renders the aggregate package.json of inv, holding every
+// package version in use, and returns it with the aliases it is keyed by.
+// Fields of config left empty get defaults.
+func RenderAggregate(inv *Inventory, config AggregateConfig) ([]byte, []Alias) {
+	aliases := buildAliases(inv.Packages)
+	manifest := aggregateManifest{
+		Name:            "pacman-aggregate",
+		Version:         "1.0.0",
+		Description:     config.Description,
+		Private:         true,
+		Engines:         config.Engines,
+		Dependencies:    make(map[string]string),
+		DevDependencies: make(map[string]string),
+	}
+	if config.Name != "" {
+		manifest.Name = config.Name
+	}
+	if config.Version != "" {
+		manifest.Version = config.Version
+	}
+	if config.Private != nil {
+		manifest.Private = *config.Private
+	}
+
+	for _, alias := range aliases {
+		if alias.Section == "devDependencies" {
+			manifest.DevDependencies[alias.Alias] = alias.Spec()
+		} else {
+			manifest.Dependencies[alias.Alias] = alias.Spec()
+		}
+	}
+
+	return marshalManifest(manifest), aliases
+}
+
+// marshalManifest encodes v the way npm writes package.json: two space
+// indentation, no HTML escaping and a trailing newline.
+func marshalManifest(v interface{}) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	// only fails for unsupported types, which the manifest types are not
+	if err := enc.Encode(v); err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+// ValidateManifest checks that data is a package.json npm would accept and
+// returns the problems found.
+func ValidateManifest(data []byte) []string {
+	var manifest struct {
+		Name            *string           `json:"name"`
+		Version         *string           `json:"version"`
+		Engines         map[string]string `json:"engines"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	err := json.Unmarshal(data, &manifest)
+	if err != nil {
+		return []string{"not valid JSON: " + err.Error()}
+	}
+
+	var problems []string
+	if manifest.Name == nil {
+		problems = append(problems, "name is missing")
+	} else if err := ValidatePackageName(*manifest.Name); err != nil {
+		problems = append(problems, "name: "+err.Error())
+	}
+	if manifest.Version == nil {
+		problems = append(problems, "version is missing")
+	} else if _, err := semver.StrictNewVersion(*manifest.Version); err != nil {
+		problems = append(problems, fmt.Sprintf("version %q is not valid semver", *manifest.Version))
+	}
+	for _, engine := range sortedKeys(manifest.Engines) {
+		if _, err := semver.NewConstraint(manifest.Engines[engine]); err != nil {
+			problems = append(problems, fmt.Sprintf("engines.%s: %q is not a valid range", engine, manifest.Engines[engine]))
+		}
+	}
+
+	seen := make(map[string]string)
+	for _, section := range []string{"dependencies", "devDependencies"} {
+		deps := manifest.Dependencies
+		if section == "devDependencies" {
+			deps = manifest.DevDependencies
+		}
+		for _, name := range sortedKeys(deps) {
+			if other, exists := seen[name]; exists {
+				problems = append(problems, fmt.Sprintf("%s.%s: also listed in %s", section, name, other))
+			}
+			seen[name] = section
+
+			if err := ValidatePackageName(name); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.%s: %s", section, name, err))
+			}
+			if err := validateDependencySpec(deps[name]); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.%s: %s", section, name, err))
+			}
+		}
+	}
+
+	return problems
+}
+
+// packageNameChars matches a package name, or the scope of a scoped one,
+// made only of the characters npm allows in new package names.
+var packageNameChars = regexp.MustCompile(`^[a-z0-9._-]+$`)
+
+// ValidatePackageName applies npm's rules for new package names.
+func ValidatePackageName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("package name is empty")
+	case len(name) > 214:
+		return fmt.Errorf("package name %q is longer than 214 characters", name)
+	case strings.TrimSpace(name) != name:
+		return fmt.Errorf("package name %q has leading or trailing spaces", name)
+	case strings.ToLower(name) != name:
+		return fmt.Errorf("package name %q has uppercase letters", name)
+	case strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_"):
+		return fmt.Errorf("package name %q starts with a period or underscore", name)
+	}
+
+	pkgName := name
+	if strings.HasPrefix(name, "@") {
+		scope, rest, found := strings.Cut(name[1:], "/")
+		if !found || !packageNameChars.MatchString(scope) {
+			return fmt.Errorf("package name %q is not a valid scoped name", name)
+		}
+		pkgName = rest
+	}
+	if !packageNameChars.MatchString(pkgName) {
+		return fmt.Errorf("package name %q has characters that are not allowed", name)
+	}
+
+	return nil
+}
+
+// validateDependencySpec accepts the specs the aggregate is made of: semver
+// versions and ranges, and npm: aliases of those.
+func validateDependencySpec(spec string) error {
+	if strings.HasPrefix(spec, "npm:") {
+		target := strings.TrimPrefix(spec, "npm:")
+		at := strings.LastIndex(target, "@")
+		if at <= 0 {
+			return fmt.Errorf("alias %q has no version", spec)
+		}
+		if err := ValidatePackageName(target[:at]); err != nil {
+			return err
+		}
+		spec = target[at+1:]
+	}
+
+	if _, err := semver.NewConstraint(spec); err != nil {
+		return fmt.Errorf("%q is not a valid version or range", spec)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderAggregate(t *testing.T) {
+	inv := newInventory(map[string]RepoInfo{}, map[string]Package{
+		"lodash":      {Name: "lodash", Versions: map[string][]string{"4.17.21": {"b"}, "4.17.19": {"a"}}},
+		"eslint":      {Name: "eslint", Versions: map[string][]string{"8.0.0": {"a", "b"}}, IsDev: true},
+		"@types/node": {Name: "@types/node", Versions: map[string][]string{"18.0.0": {"a"}}, IsDev: true},
+		"express":     {Name: "express", Versions: map[string][]string{"4.18.2": {"b"}}},
+	})
+	private := false
+
+	tests := []struct {
+		name   string
+		config AggregateConfig
+		want   string
+	}{
+		{
+			name: "defaults",
+			want: `{
+  "name": "pacman-aggregate",
+  "version": "1.0.0",
+  "private": true,
+  "dependencies": {
+    "express": "4.18.2",
+    "lodash-v4.17.19": "npm:lodash@4.17.19",
+    "lodash-v4.17.21": "npm:lodash@4.17.21"
+  },
+  "devDependencies": {
+    "@types/node": "18.0.0",
+    "eslint": "8.0.0"
+  }
+}
+`,
+		},
+		{
+			name: "config",
+			config: AggregateConfig{
+				Name:        "fleet",
+				Version:     "2.0.0",
+				Description: "Every dependency of the fleet",
+				Private:     &private,
+				Engines:     map[string]string{"node": ">=18"},
+			},
+			want: `{
+  "name": "fleet",
+  "version": "2.0.0",
+  "description": "Every dependency of the fleet",
+  "private": false,
+  "engines": {
+    "node": ">=18"
+  },
+  "dependencies": {
+    "express": "4.18.2",
+    "lodash-v4.17.19": "npm:lodash@4.17.19",
+    "lodash-v4.17.21": "npm:lodash@4.17.21"
+  },
+  "devDependencies": {
+    "@types/node": "18.0.0",
+    "eslint": "8.0.0"
+  }
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		manifest, aliases := RenderAggregate(inv, test.config)
+		if string(manifest) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, manifest, test.want)
+		}
+		if len(aliases) != 5 {
+			t.Errorf("%s: aliases %v", test.name, aliases)
+		}
+		if problems := ValidateManifest(manifest); len(problems) > 0 {
+			t.Errorf("%s: the aggregate is not installable: %v", test.name, problems)
+		}
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name:     "valid",
+			manifest: `{"name": "@acme/app", "version": "1.0.0", "engines": {"node": ">=18"}, "dependencies": {"a": "^1.0.0", "b-v1": "npm:b@1.0.0"}}`,
+		},
+		{
+			name:     "not json",
+			manifest: `{"name": `,
+			want:     []string{"not valid JSON: unexpected end of JSON input"},
+		},
+		{
+			name:     "missing fields",
+			manifest: `{}`,
+			want:     []string{"name is missing", "version is missing"},
+		},
+		{
+			name:     "bad fields",
+			manifest: `{"name": "App", "version": "1.0", "engines": {"node": "latest"}}`,
+			want: []string{
+				`name: package name "App" has uppercase letters`,
+				`version "1.0" is not valid semver`,
+				`engines.node: "latest" is not a valid range`,
+			},
+		},
+		{
+			name:     "bad dependencies",
+			manifest: `{"name": "app", "version": "1.0.0", "dependencies": {"a": "^1.0.0", "_b": "1.0.0", "c": "github:c/c", "d": "npm:d"}, "devDependencies": {"a": "1.0.0"}}`,
+			want: []string{
+				`dependencies._b: package name "_b" starts with a period or underscore`,
+				`dependencies.c: "github:c/c" is not a valid version or range`,
+				`dependencies.d: alias "npm:d" has no version`,
+				`devDependencies.a: also listed in dependencies`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if got := ValidateManifest([]byte(test.manifest)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ValidateManifest() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
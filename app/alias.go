@@ -0,0 +1,116 @@
+package app
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const aliasMapFileName = "aliases.json"
+
+// Alias is a dependency key of the aggregate package.json. Packages used at a
+// single version keep their own name as key; packages with several versions
+// get one alias per version, e.g. lodash-v4.17.19 for npm:lodash@4.17.19.
+type Alias struct {
+	Alias   string   `json:"alias"`
+	Package string   `json:"package"`
+	Version string   `json:"version"`
+	Section string   `json:"section"`
+	Repos   []string `json:"repos"`
+}
+
+// aliasUnsafe matches characters that may not appear in an npm package name.
+var aliasUnsafe = regexp.MustCompile(`[^a-z0-9._-]`)
+
+// buildAliases assigns a key in the aggregate package.json to every package
+// version. Keys are derived from the package name and the full version, and
+// are assigned in sorted order, so the result is the same on every run.
+// Should two versions still map to the same key, for example 1.0.0+a and
+// 1.0.0-a, the later one gets a numeric suffix rather than replacing the
+// earlier one.
+func buildAliases(packages map[string]Package) []Alias {
+	taken := make(map[string]bool)
+	// real package names always win over aliases
+	for name, pkg := range packages {
+		if len(pkg.Versions) == 1 {
+			taken[name] = true
+		}
+	}
+
+	var aliases []Alias
+	for _, name := range sortedKeys(packages) {
+		pkg := packages[name]
+		section := "dependencies"
+		if pkg.IsDev {
+			section = "devDependencies"
+		}
+
+		for _, version := range sortedVersions(pkg.Versions) {
+			alias := Alias{
+				Alias:   name,
+				Package: name,
+				Version: version,
+				Section: section,
+				Repos:   pkg.Versions[version],
+			}
+			if len(pkg.Versions) > 1 {
+				alias.Alias = uniqueAlias(name+"-v"+aliasUnsafe.ReplaceAllString(strings.ToLower(version), "-"), taken)
+			}
+			aliases = append(aliases, alias)
+		}
+	}
+
+	return aliases
+}
+
+func uniqueAlias(alias string, taken map[string]bool) string {
+	candidate := alias
+	for i := 2; taken[candidate]; i++ {
+		candidate = alias + "-" + strconv.Itoa(i)
+	}
+	taken[candidate] = true
+
+	return candidate
+}
+
+// sortedVersions returns the keys of versions in ascending semver order.
+// Versions that are not valid semver sort after the valid ones, by string.
+func sortedVersions(versions map[string][]string) []string {
+	keys := sortedKeys(versions)
+	sort.SliceStable(keys, func(i, j int) bool {
+		vi, errI := semver.NewVersion(keys[i])
+		vj, errJ := semver.NewVersion(keys[j])
+		switch {
+		case errI != nil || errJ != nil:
+			return errI == nil && errJ != nil
+		default:
+			return vi.LessThan(vj)
+		}
+	})
+
+	return keys
+}
+
+// Spec is the dependency spec written for the alias in the aggregate
+// package.json.
+func (a Alias) Spec() string {
+	if a.Alias == a.Package {
+		return a.Version
+	}
+
+	return "npm:" + a.Package + "@" + a.Version
+}
+
+// RenderAliasMap encodes aliases.json, which maps every key of the aggregate
+// package.json back to its package, version and repos.
+func RenderAliasMap(aliases []Alias) []byte {
+	aliasMap := make(map[string]Alias, len(aliases))
+	for _, alias := range aliases {
+		aliasMap[alias.Alias] = alias
+	}
+
+	return marshalManifest(aliasMap)
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildAliases(t *testing.T) {
+	packages := map[string]Package{
+		"single": {Name: "single", Versions: map[string][]string{"1.0.0": {"a", "b"}}},
+		"dev":    {Name: "dev", Versions: map[string][]string{"2.0.0": {"b"}, "1.0.0": {"a"}}, IsDev: true},
+		// a real package named like an alias of dep keeps its name
+		"dep-v1.0.0": {Name: "dep-v1.0.0", Versions: map[string][]string{"3.0.0": {"c"}}},
+		"dep":        {Name: "dep", Versions: map[string][]string{"1.0.0": {"a"}, "2.0.0-Beta.1": {"b"}}},
+		// 1.0.0-a and 1.0.0+a make the same alias
+		"meta": {Name: "meta", Versions: map[string][]string{"1.0.0+a": {"b"}, "1.0.0-a": {"a"}}},
+	}
+	want := []Alias{
+		{"dep-v1.0.0-2", "dep", "1.0.0", "dependencies", []string{"a"}},
+		{"dep-v2.0.0-beta.1", "dep", "2.0.0-Beta.1", "dependencies", []string{"b"}},
+		{"dep-v1.0.0", "dep-v1.0.0", "3.0.0", "dependencies", []string{"c"}},
+		{"dev-v1.0.0", "dev", "1.0.0", "devDependencies", []string{"a"}},
+		{"dev-v2.0.0", "dev", "2.0.0", "devDependencies", []string{"b"}},
+		{"meta-v1.0.0-a", "meta", "1.0.0-a", "dependencies", []string{"a"}},
+		{"meta-v1.0.0-a-2", "meta", "1.0.0+a", "dependencies", []string{"b"}},
+		{"single", "single", "1.0.0", "dependencies", []string{"a", "b"}},
+	}
+
+	// the same on every run, whatever the map order
+	for i := 0; i < 5; i++ {
+		if got := buildAliases(packages); !reflect.DeepEqual(got, want) {
+			t.Fatalf("buildAliases() =\n%v\nwant\n%v", got, want)
+		}
+	}
+}
+
+func TestAliasSpec(t *testing.T) {
+	tests := []struct {
+		alias Alias
+		want  string
+	}{
+		{Alias{Alias: "lodash", Package: "lodash", Version: "4.17.21"}, "4.17.21"},
+		{Alias{Alias: "lodash-v4.17.21", Package: "lodash", Version: "4.17.21"}, "npm:lodash@4.17.21"},
+		{Alias{Alias: "@types/node-v18.0.0", Package: "@types/node", Version: "18.0.0"}, "npm:@types/node@18.0.0"},
+	}
+	for _, test := range tests {
+		if got := test.alias.Spec(); got != test.want {
+			t.Errorf("%s: Spec() = %q, want %q", test.alias.Alias, got, test.want)
+		}
+	}
+}
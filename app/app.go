@@ -1,19 +1,18 @@
 package app
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
 	"encoding/json"
+	"fmt"
+	"io/fs"
 	"log"
 	"os"
-	"regexp"
-	"sort"
+	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/Jeffail/gabs/v2"
-	"github.com/Masterminds/semver/v3"
 	"github.com/google/go-github/v44/github"
 	"golang.org/x/oauth2"
 )
@@ -24,9 +23,9 @@ type PackageDependencies struct {
 }
 
 type Package struct {
-	Name     string
-	Versions map[string][]string
-	IsDev    bool
+	Name     string              `json:"name"`
+	Versions map[string][]string `json:"versions"`
+	IsDev    bool                `json:"isDev"`
 }
 
 func IsValidDir(dir string) bool {
@@ -38,65 +37,132 @@ func IsValidDir(dir string) bool {
 	return true
 }
 
-func Parse(dir string) {
-	f, err := os.Open(dir)
+// RepoManifest is the package.json of one repo, as read from a Source.
+type RepoManifest struct {
+	Repo string
+	Info RepoInfo
+	Data []byte
+}
+
+// Source provides the manifests an inventory is built from.
+type Source interface {
+	Manifests(ctx context.Context) ([]RepoManifest, error)
+}
+
+// DirSource reads the package.json of every top-level directory of FS.
+type DirSource struct {
+	FS fs.FS
+	// Dir is the directory FS is rooted at, when it is on disk. It is
+	// recorded as the location of each repo and used to look up commits.
+	Dir string
+}
+
+// NewDirSource returns a DirSource for a directory on disk.
+func NewDirSource(dir string) *DirSource {
+	return &DirSource{FS: os.DirFS(dir), Dir: dir}
+}
+
+func (s *DirSource) Manifests(ctx context.Context) ([]RepoManifest, error) {
+	entries, err := fs.ReadDir(s.FS, ".")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	contents, err := f.Readdirnames(0)
-	repoPkgs := make(map[string]PackageDependencies)
+	var manifests []RepoManifest
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-	for _, subdir := range contents {
-		data, err := os.ReadFile(dir + "/" + subdir + "/package.json")
-		var pkgDeps PackageDependencies
+		subdir := entry.Name()
+		data, err := fs.ReadFile(s.FS, path.Join(subdir, "package.json"))
+		if err != nil {
+			continue
+		}
 
-		if err == nil {
-			err := json.Unmarshal(data, &pkgDeps)
-			if err != nil {
-				log.Println("error parsing package.json for :", subdir, err)
+		info := RepoInfo{
+			Source:       SourceDir,
+			Location:     subdir,
+			ManifestPath: path.Join(subdir, "package.json"),
+		}
+		if s.Dir != "" {
+			// absolute, so that update finds the checkout from any directory
+			repoDir := s.Dir + "/" + subdir
+			if abs, err := filepath.Abs(repoDir); err == nil {
+				repoDir = abs
 			}
-			repoPkgs[subdir] = pkgDeps
+			info.Location = repoDir
+			info.ManifestPath = repoDir + "/package.json"
+			info.Commit = gitHeadCommit(repoDir)
 		}
+		manifests = append(manifests, RepoManifest{Repo: subdir, Info: info, Data: data})
 	}
 
-	extractPackages(repoPkgs)
+	return manifests, nil
 }
 
-func extractPackages(repoPkgs map[string]PackageDependencies) {
+// gitHeadCommit returns the commit checked out in dir, or an empty string when
+// dir is not a git work tree.
+func gitHeadCommit(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// BuildInventory reads every manifest of src and collects their
+// dependencies into an inventory.
+func BuildInventory(ctx context.Context, src Source) (*Inventory, error) {
+	manifests, err := src.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repoPkgs := make(map[string]PackageDependencies)
+	repoInfos := make(map[string]RepoInfo)
+	for _, manifest := range manifests {
+		var pkgDeps PackageDependencies
+		err := json.Unmarshal(manifest.Data, &pkgDeps)
+		if err != nil {
+			log.Println("error parsing package.json for :", manifest.Repo, err)
+		}
+		info := manifest.Info
+		info.Dependencies = pkgDeps.Dependencies
+		info.DevDependencies = pkgDeps.DevDependencies
+		repoPkgs[manifest.Repo] = pkgDeps
+		repoInfos[manifest.Repo] = info
+	}
+
+	return extractPackages(repoPkgs, repoInfos), nil
+}
+
+func extractPackages(repoPkgs map[string]PackageDependencies, repoInfos map[string]RepoInfo) *Inventory {
 	allPkgs := make(map[string]Package)
 
-	for repo, pkgs := range repoPkgs {
+	// walk repos in a fixed order so that repo lists, and the section of
+	// packages listed differently across repos, are the same on every run
+	for _, repo := range sortedKeys(repoPkgs) {
+		pkgs := repoPkgs[repo]
 		log.Println("Extracting packages from repo : ", repo)
 		log.Println("Number of dependencies : ", len(pkgs.Dependencies))
 		log.Println("Number of dev dependencies : ", len(pkgs.DevDependencies))
 		allPkgs = transform(allPkgs, repo, pkgs.Dependencies, false)
 		allPkgs = transform(allPkgs, repo, pkgs.DevDependencies, true)
 	}
-	writeEncodedMapToFile(allPkgs)
-	writePackagesWRepoToFile(allPkgs)
-	writeBasePackageJsonToFile(allPkgs)
-}
-
-func writeEncodedMapToFile(packages map[string]Package) {
-	b := new(bytes.Buffer)
-	e := gob.NewEncoder(b)
 
-	encodeErr := e.Encode(packages)
-	if encodeErr != nil {
-		log.Fatal("Failed to encode map to binary", encodeErr)
-	}
-	ioErr := os.WriteFile("packages.gob", b.Bytes(), 0666)
-	if ioErr != nil {
-		log.Fatal("Failed to write file", ioErr)
-	}
+	return newInventory(repoInfos, allPkgs)
 }
 
-func writePackagesWRepoToFile(packages map[string]Package) {
+// RenderPackagesList renders packages_list.json, which lists the repos
+// using each version of each package.
+func RenderPackagesList(inv *Inventory) []byte {
 	pkgJson := gabs.New()
 	pkgJson.Array("dependencies")
 	pkgJson.Array("devDependencies")
-	for _, pkg := range packages {
+	for _, name := range sortedKeys(inv.Packages) {
+		pkg := inv.Packages[name]
 		jsonObj := gabs.New()
 		for version, repos := range pkg.Versions {
 			jsonObj.Set(repos, pkg.Name, version)
@@ -108,46 +174,28 @@ func writePackagesWRepoToFile(packages map[string]Package) {
 		}
 	}
 
-	err := os.WriteFile("packages_list.json", pkgJson.Bytes(), 0666)
-	if err != nil {
-		log.Fatal("Failed to create packages_list.json")
-	}
+	return pkgJson.Bytes()
 }
 
-func writeBasePackageJsonToFile(packages map[string]Package) {
-	pkgJson := gabs.New()
-	aliasRegexp := regexp.MustCompile(`[.~^]`)
-	for _, pkg := range packages {
-		var outerKey string
-
-		if pkg.IsDev {
-			outerKey = "devDependencies"
-		} else {
-			outerKey = "dependencies"
-		}
-
-		for version := range pkg.Versions {
-			if len(pkg.Versions) == 1 {
-				pkgJson.Set(version, outerKey, pkg.Name)
-			} else {
-				alias := pkg.Name + ":" + aliasRegexp.ReplaceAllString(version, "")
-				pkgJson.Set("npm:"+pkg.Name+"@"+version, outerKey, alias)
-			}
-		}
+// inventoryVersion returns the version the inventory records for a
+// dependency declared with spec: the version of a spec made of an operator
+// and a single version, such as ^1.2.3, or else the spec itself, such as 1.x,
+// latest or a git URL.
+func inventoryVersion(spec string) string {
+	if _, version, ok := specOperator(spec); ok {
+		return version
 	}
 
-	err := os.WriteFile("package.json", pkgJson.Bytes(), 0666)
-	if err != nil {
-		log.Fatal("Failed to create package.json")
-	}
+	return strings.TrimSpace(spec)
 }
 
 func transform(packages map[string]Package, repo string, dependencies map[string]string, isDev bool) map[string]Package {
-	for pkg, version := range dependencies {
-		// remove version modifiers
-		digitRegexp := regexp.MustCompile(`^[0-9]+$`)
-		if !digitRegexp.MatchString(version[0:1]) {
-			version = version[1:]
+	for pkg, spec := range dependencies {
+		version := inventoryVersion(spec)
+		if version == "" {
+			// valid in package.json, but there is no version to record
+			log.Printf("Skipping %s in %s: no version\n", pkg, repo)
+			continue
 		}
 		if existingPkg, exists := packages[pkg]; exists {
 			// package already exists in common deps
@@ -174,167 +222,118 @@ func transform(packages map[string]Package, repo string, dependencies map[string
 	return packages
 }
 
-func Unify(isMinor bool) {
-	var modifier string
-
-	backupFiles()
-	packages := readEncodedMapFromFile()
-
-	if isMinor {
-		modifier = "^"
-	} else {
-		modifier = "~"
-	}
-
-	for _, pkg := range packages {
-		if len(pkg.Versions) == 1 {
-			continue
-		}
-
-		versions := make([]*semver.Version, 0, len(pkg.Versions))
-		for version := range pkg.Versions {
-			v, err := semver.NewVersion(version)
-			if err != nil {
-				log.Printf("Failed to parse version %s for package %s\n", version, pkg.Name)
-				continue
-			}
-			versions = append(versions, v)
-		}
-
-		sort.Sort(semver.Collection(versions))
-
-		for i := 0; i < len(versions); i++ {
-			c, err := semver.NewConstraint(modifier + versions[i].String())
-			if err != nil {
-				log.Printf("Failed to parse contraint %s\n", versions[i])
-				continue
-			}
-			for j := i + 1; j < len(versions); j++ {
-				if valid, _ := c.Validate(versions[j]); valid {
-					log.Printf("Found similar version %s to %s for package %s\n", versions[j], versions[i], pkg.Name)
-					copy(pkg.Versions[versions[j].String()], pkg.Versions[versions[i].String()])
-					delete(pkg.Versions, versions[i].String())
-					i += 1
-				}
-			}
-		}
-	}
-
-	writeEncodedMapToFile(packages)
-	writePackagesWRepoToFile(packages)
-	writeBasePackageJsonToFile(packages)
+// UpdateResult is the outcome of UpdateManifest.
+type UpdateResult struct {
+	Repo string
+	// Manifest is the updated package.json.
+	Manifest []byte
+	Changes  []DependencyChange
+	// Excluded are the dependencies left as they are, because of the
+	// exclusions passed to UpdateManifest or because their spec cannot be
+	// rewritten in the range style asked for.
+	Excluded []Exclusion
 }
 
-func readEncodedMapFromFile() map[string]Package {
-	data, ioErr := os.ReadFile("packages.gob")
-	if os.IsNotExist(ioErr) {
-		log.Fatal("packages.gob file missing. Run parse to create it")
-	}
-
-	var decodedMap map[string]Package
-	r := bytes.NewReader(data)
-	d := gob.NewDecoder(r)
-
-	decodeErr := d.Decode(&decodedMap)
-	if decodeErr != nil {
-		log.Fatal("Failed to decode packages.gob", decodeErr)
-	}
-
-	return decodedMap
+// DependencyChange is a dependency whose spec was changed in a manifest.
+type DependencyChange struct {
+	Package string `json:"package"`
+	Section string `json:"section"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	// OperatorChanged is set when the range operator changed, for example
+	// from an exact pin to a caret range, and not just the version.
+	OperatorChanged bool `json:"operatorChanged"`
+	// Reason says why the spec changed.
+	Reason string `json:"reason"`
 }
 
-func backupFiles() {
-	file, err := os.Stat("package.json")
-	if err == nil && file != nil {
-		os.Rename("package.json", "package.json"+"_"+time.Now().Format("20060102150405"))
+// UpdateManifest sets every dependency of repo's manifest whose version the
+// inventory has changed to the new version, in the range style opts selects,
+// except those opts.Exclusions leave alone. Only specs made of an operator and
+// a single version, such as ^1.2.3, are rewritten; other specs are left
+// exactly as they are. Only the changed version strings are rewritten; key
+// order, indentation, line endings and the final newline of manifest are
+// kept.
+func UpdateManifest(ctx context.Context, inv *Inventory, repo string, manifest []byte, opts UpdateOptions) (*UpdateResult, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
 	}
+	exclusions := opts.Exclusions
 
-	file, err = os.Stat("packages_list.json")
-	if err == nil && file != nil {
-		os.Rename("packages_list.json", "packages_list.json"+"_"+time.Now().Format("20060102150405"))
+	var pkgDeps PackageDependencies
+	err = json.Unmarshal(manifest, &pkgDeps)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing package.json for %s: %w", repo, err)
 	}
-}
-
-func Update(dir string) {
-	backupFiles()
-	packages := readEncodedMapFromFile()
-	pkgDeps := unmarshallPackageJson(dir)
-	repoName := extractRepoNameFromDir(dir)
-
-	jsonObj := parseJsonUsingGabs(dir)
 
-	for pkg, version := range pkgDeps.Dependencies {
-		// remove version modifiers
-		digitRegexp := regexp.MustCompile(`^[0-9]+$`)
-		if !digitRegexp.MatchString(version[0:1]) {
-			version = version[1:]
+	result := &UpdateResult{Repo: repo, Manifest: manifest}
+	for _, section := range []string{"dependencies", "devDependencies"} {
+		deps := pkgDeps.Dependencies
+		if section == "devDependencies" {
+			deps = pkgDeps.DevDependencies
 		}
 
-		if pkg, exists := packages[pkg]; exists {
+		for _, name := range sortedKeys(deps) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			pkg, exists := inv.Packages[name]
+			if !exists {
+				continue
+			}
+			if reason, ignored := exclusions.ignoredPackage(name); ignored {
+				result.Excluded = append(result.Excluded, Exclusion{Package: name, Reason: reason})
+				continue
+			}
 			for v, repos := range pkg.Versions {
 				for _, r := range repos {
-					if r == repoName {
-						jsonObj.Set("^"+v, "dependencies", pkg.Name)
+					if r != repo {
+						continue
+					}
+					if reason, held := exclusions.heldRepo(name, repo, v); held {
+						result.Excluded = append(result.Excluded, Exclusion{name, repo, v, reason})
+						continue
+					}
+					oldOp, oldVersion, simple := specOperator(deps[name])
+					if !simple {
+						// ranges, x-ranges, tags, git and file specs and npm:
+						// aliases are never rewritten
+						if v != inventoryVersion(deps[name]) {
+							result.Excluded = append(result.Excluded, Exclusion{name, repo, v,
+								fmt.Sprintf("spec %q is not a single version", deps[name])})
+						}
+						continue
+					}
+					if oldVersion == v {
+						continue
+					}
+					op, why, err := opts.operatorFor(name, deps[name])
+					if err != nil {
+						result.Excluded = append(result.Excluded, Exclusion{name, repo, v, err.Error()})
+						continue
+					}
+					log.Println("Updating dependency ", pkg.Name, v, deps[name])
+					result.Manifest, err = setStringMember(result.Manifest, op+v, section, name)
+					if err != nil {
+						return nil, fmt.Errorf("error updating package.json for %s: %w", repo, err)
 					}
-				}
-			}
-		}
-	}
-
-	for pkg, version := range pkgDeps.DevDependencies {
-		// remove version modifiers
-		digitRegexp := regexp.MustCompile(`^[0-9]+$`)
-		if !digitRegexp.MatchString(version[0:1]) {
-			version = version[1:]
-		}
 
-		if pkg, exists := packages[pkg]; exists {
-			for v, repos := range pkg.Versions {
-				for _, r := range repos {
-					if r == repoName {
-						log.Println("Updating dependency ", pkg.Name, v, version)
-						jsonObj.Set("^"+v, "devDependencies", pkg.Name)
+					change := DependencyChange{Package: name, Section: section, From: deps[name], To: op + v}
+					// = and no operator both pin the exact version
+					change.OperatorChanged = strings.TrimPrefix(oldOp, "=") != strings.TrimPrefix(op, "=")
+					reasons := []string{"inventory has " + v}
+					if change.OperatorChanged {
+						reasons = append(reasons, why)
 					}
+					change.Reason = strings.Join(reasons, "; ")
+					result.Changes = append(result.Changes, change)
 				}
 			}
 		}
 	}
 
-	writeToFile(jsonObj, dir)
-}
-
-func unmarshallPackageJson(dir string) PackageDependencies {
-	data, err := os.ReadFile(dir + "/package.json")
-	var pkgDeps PackageDependencies
-
-	if err == nil {
-		err := json.Unmarshal(data, &pkgDeps)
-		if err != nil {
-			log.Println("error parsing package.json for :", dir, err)
-		}
-	}
-
-	return pkgDeps
-}
-
-func extractRepoNameFromDir(dir string) string {
-	p := strings.Split(dir, "/")
-	return p[len(p)-1]
-}
-
-func parseJsonUsingGabs(dir string) *gabs.Container {
-	data, _ := os.ReadFile(dir + "/package.json")
-	jsonParsed, err := gabs.ParseJSON(data)
-
-	if err != nil {
-		log.Println("error parsing package.json for :", dir, err)
-	}
-
-	return jsonParsed
-}
-
-func writeToFile(jsonObj *gabs.Container, dir string) {
-	os.WriteFile(dir+"/package.json_test", jsonObj.Bytes(), 0666)
+	return result, nil
 }
 
 func IsValidFile(repoListPath string) bool {
@@ -346,46 +345,67 @@ func IsValidFile(repoListPath string) bool {
 	return true
 }
 
-func ParseByRepo(repoListPath string) {
+// ReadRepoList reads a file listing one owner/name repo per line.
+func ReadRepoList(repoListPath string) ([]string, error) {
 	f, err := os.ReadFile(repoListPath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	repos := strings.Fields(string(f))
-	getPackageJsonFilesFromGithub(repos)
+
+	return strings.Fields(string(f)), nil
 }
 
-func getPackageJsonFilesFromGithub(repos []string) {
-	ctx := context.Background()
-	client := authToGithub()
-	repoPkgs := make(map[string]PackageDependencies)
+// GitHubSource reads the package.json of each repo from GitHub, at the head
+// of its default branch.
+type GitHubSource struct {
+	Client *github.Client
+	// Repos are given as owner/name.
+	Repos []string
+}
 
-	for _, repo := range repos {
-		repoOwner := strings.Split(repo, "/")[0]
-		repoName := strings.Split(repo, "/")[1]
-		fileContents, _, _, err := client.Repositories.GetContents(ctx, repoOwner, repoName, "package.json", nil)
+func (s *GitHubSource) Manifests(ctx context.Context) ([]RepoManifest, error) {
+	var manifests []RepoManifest
+
+	for _, repo := range s.Repos {
+		repoOwner, repoName, found := strings.Cut(repo, "/")
+		if !found {
+			return nil, fmt.Errorf("invalid repo %q: expected owner/name", repo)
+		}
+		sha, _, err := s.Client.Repositories.GetCommitSHA1(ctx, repoOwner, repoName, "HEAD", "")
+		if err != nil {
+			log.Printf("Repositories.GetCommitSHA1 returned error: %v\n", err)
+		}
+		fileContents, _, _, err := s.Client.Repositories.GetContents(ctx, repoOwner, repoName, "package.json",
+			&github.RepositoryContentGetOptions{Ref: sha})
 		if err != nil {
 			log.Printf("Repositories.GetContents returned error: %v\n", err)
-		} else {
-			fileString, _ := fileContents.GetContent()
-			var pkgDeps PackageDependencies
-
-			if err == nil {
-				err := json.Unmarshal([]byte(fileString), &pkgDeps)
-				if err != nil {
-					log.Println("error parsing package.json for :", repo, err)
-				}
-				repoPkgs[repo] = pkgDeps
-			}
+			continue
+		}
+		fileString, err := fileContents.GetContent()
+		if err != nil {
+			log.Println("error decoding package.json for :", repo, err)
+			continue
 		}
+
+		manifests = append(manifests, RepoManifest{
+			Repo: repo,
+			Info: RepoInfo{
+				Source:       SourceGithub,
+				Location:     repo,
+				ManifestPath: "package.json",
+				Commit:       sha,
+			},
+			Data: []byte(fileString),
+		})
 	}
-	extractPackages(repoPkgs)
+
+	return manifests, nil
 }
 
-func authToGithub() *github.Client {
-	ctx := context.Background()
+// NewGitHubClient returns a GitHub client authenticated with token.
+func NewGitHubClient(ctx context.Context, token string) *github.Client {
 	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_PAT")},
+		&oauth2.Token{AccessToken: token},
 	)
 	tc := oauth2.NewClient(ctx, ts)
 
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+// manifestWith returns a package.json declaring dep with spec.
+func manifestWith(spec string) []byte {
+	return []byte("{\n  \"name\": \"a\",\n  \"dependencies\": {\n    \"dep\": " + strconv.Quote(spec) + "\n  }\n}\n")
+}
+
+// manifestsWith returns, for each repo in specs, a package.json declaring
+// dep with the spec it has.
+func manifestsWith(specs map[string]string) map[string][]byte {
+	manifests := make(map[string][]byte, len(specs))
+	for repo, spec := range specs {
+		manifests[repo] = manifestWith(spec)
+	}
+
+	return manifests
+}
+
+// inventoryOf builds an inventory from manifests, the package.json of each
+// repo by name, as parse does.
+func inventoryOf(t *testing.T, manifests map[string][]byte) *Inventory {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for repo, manifest := range manifests {
+		fsys[repo+"/package.json"] = &fstest.MapFile{Data: manifest}
+	}
+	inv, err := BuildInventory(context.Background(), &DirSource{FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return inv
+}
+
+// parseManifest builds an inventory from manifest as the package.json of
+// repo a, as parse does.
+func parseManifest(t *testing.T, manifest []byte) *Inventory {
+	t.Helper()
+	return inventoryOf(t, map[string][]byte{"a": manifest})
+}
+
+// moveTo moves every repo using pkg in inv to version, as unify does.
+func moveTo(inv *Inventory, pkg string, version string) {
+	p, exists := inv.Packages[pkg]
+	if !exists {
+		return
+	}
+	for from := range p.Versions {
+		if from != version {
+			mergeVersion(p, from, version)
+		}
+	}
+}
+
+func TestUpdateManifestRoundTrip(t *testing.T) {
+	tests := []struct {
+		spec string
+		// want is the spec after unify moves dep to 4.17.21, or empty when
+		// it must be left as it is.
+		want string
+	}{
+		{"4.17.1", "4.17.21"},
+		{"^4.17.1", "^4.17.21"},
+		{"~4.17.1", "~4.17.21"},
+		{">=4.17.1", ">=4.17.21"},
+		{"=4.17.1", "=4.17.21"},
+		{"v4.17.1", "4.17.21"},
+		{" 4.17.1 ", "4.17.21"},
+		{"^4.17.1-beta.1", "^4.17.21"},
+		{"1.x", ""},
+		{"4", ""},
+		{"^1.2.3 || ^2.0.0", ""},
+		{">=1.0.0 <3.0.0", ""},
+		{"*", ""},
+		{"latest", ""},
+		{"next", ""},
+		{"git+https://github.com/lodash/lodash.git", ""},
+		{"github:lodash/lodash#4.17.21", ""},
+		{"file:../lodash", ""},
+		{"npm:lodash@^4.17.0", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			manifest := manifestWith(test.spec)
+
+			// parse and update straight away: nothing changes
+			inv := parseManifest(t, manifest)
+			result, err := UpdateManifest(context.Background(), inv, "a", manifest, UpdateOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result.Manifest) != string(manifest) || len(result.Changes) > 0 {
+				t.Errorf("unchanged inventory rewrote %q: %s, changes %v", test.spec, result.Manifest, result.Changes)
+			}
+
+			// parse, unify onto a new version and update
+			moveTo(inv, "dep", "4.17.21")
+			result, err = UpdateManifest(context.Background(), inv, "a", manifest, UpdateOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := manifest
+			if test.want != "" {
+				want = manifestWith(test.want)
+			}
+			if string(result.Manifest) != string(want) {
+				t.Errorf("updating %q wrote\n%s\nwant\n%s", test.spec, result.Manifest, want)
+			}
+			if changed := test.want != ""; changed != (len(result.Changes) == 1) {
+				t.Errorf("updating %q: changes %v", test.spec, result.Changes)
+			}
+		})
+	}
+}
+
+func TestUpdateManifestRangeStyles(t *testing.T) {
+	tests := []struct {
+		spec  string
+		style string
+		want  string
+		// operatorChanged is whether the change reports a new operator.
+		operatorChanged bool
+	}{
+		{"4.17.1", RangeKeep, "4.17.21", false},
+		{"4.17.1", RangeCaret, "^4.17.21", true},
+		{"^4.17.1", RangeExact, "4.17.21", true},
+		{"^4.17.1", RangeTilde, "~4.17.21", true},
+		{"~4.17.1", RangeTilde, "~4.17.21", false},
+		{"=4.17.1", RangeExact, "4.17.21", false},
+		{"1.x", RangeCaret, "1.x", false},
+	}
+
+	for _, test := range tests {
+		manifest := manifestWith(test.spec)
+		inv := parseManifest(t, manifest)
+		moveTo(inv, "dep", "4.17.21")
+		result, err := UpdateManifest(context.Background(), inv, "a", manifest, UpdateOptions{RangeStyle: test.style})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(result.Manifest), string(manifestWith(test.want)); got != want {
+			t.Errorf("%q with style %s: got\n%s\nwant\n%s", test.spec, test.style, got, want)
+		}
+		if len(result.Changes) == 1 && result.Changes[0].OperatorChanged != test.operatorChanged {
+			t.Errorf("%q with style %s: OperatorChanged = %v", test.spec, test.style, result.Changes[0].OperatorChanged)
+		}
+	}
+}
+
+func TestInventoryVersion(t *testing.T) {
+	tests := map[string]string{
+		"^4.17.1":  "4.17.1",
+		">=4.17.1": "4.17.1",
+		"=4.17.1":  "4.17.1",
+		"v1.2.3":   "1.2.3",
+		" ~1.2.3 ": "1.2.3",
+		"1.x":      "1.x",
+		"latest":   "latest",
+		"":         "",
+	}
+	for spec, want := range tests {
+		if got := inventoryVersion(spec); got != want {
+			t.Errorf("inventoryVersion(%q) = %q, want %q", spec, got, want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	backupDirName     = "backups"
+	defaultBackupKeep = 10
+)
+
+// BackupConfig controls how many backups are retained. Backups beyond Keep,
+// or older than MaxAge, are pruned whenever a new backup is taken. Snapshots
+// are retained the same way whenever a new one is saved.
+type BackupConfig struct {
+	Keep   int    `json:"keep"`
+	MaxAge string `json:"maxAge"`
+}
+
+// expired reports whether the i-th oldest of n backups or snapshots, taken
+// at t, is beyond Keep or older than MaxAge. The newest is never expired.
+func (c BackupConfig) expired(i int, n int, t time.Time) bool {
+	if i == n-1 {
+		return false
+	}
+	var maxAge time.Duration
+	if c.MaxAge != "" {
+		maxAge, _ = parseAge(c.MaxAge)
+	}
+
+	return n-i > c.Keep || (maxAge > 0 && time.Since(t) > maxAge)
+}
+
+// Backup is a set of state files saved before pacman changed them.
+type Backup struct {
+	Name  string
+	Time  time.Time
+	Files []string
+	// seq orders backups taken within the same second
+	seq int
+}
+
+// stateFiles are the files in the workdir that make up pacman's state. They
+// are backed up and restored together.
+func stateFiles() []string {
+	return []string{stateFileName, "package.json", "packages_list.json", aliasMapFileName}
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// into place, so a crash never leaves a truncated file behind.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+
+	return err
+}
+
+// Backup copies the current state files into a new timestamped backup
+// directory and prunes old backups.
+func (w *Workspace) Backup() error {
+	var existing []string
+	for _, name := range stateFiles() {
+		if IsValidFile(w.path(name)) {
+			existing = append(existing, name)
+		}
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+
+	dir, err := w.newBackupDir()
+	if err != nil {
+		return err
+	}
+	for _, name := range existing {
+		data, err := os.ReadFile(w.path(name))
+		if err != nil {
+			return fmt.Errorf("failed to read %s for backup: %w", name, err)
+		}
+		err = WriteFileAtomic(filepath.Join(dir, name), data, 0666)
+		if err != nil {
+			return fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+	}
+
+	return w.pruneBackups()
+}
+
+// newBackupDir creates the directory for a backup taken now. Backups taken
+// within the same second get a numeric suffix.
+func (w *Workspace) newBackupDir() (string, error) {
+	err := os.MkdirAll(w.path(backupDirName), 0777)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", backupDirName, err)
+	}
+
+	name := time.Now().Format(timestampFormat)
+	dir := w.path(filepath.Join(backupDirName, name))
+	for i := 2; ; i++ {
+		err = os.Mkdir(dir, 0777)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("failed to create backup %s: %w", dir, err)
+		}
+		dir = w.path(filepath.Join(backupDirName, name+"-"+strconv.Itoa(i)))
+	}
+}
+
+// ListBackups returns the available backups, oldest first.
+func (w *Workspace) ListBackups() ([]Backup, error) {
+	entries, err := os.ReadDir(w.path(backupDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		stamp, suffix, _ := strings.Cut(entry.Name(), "-")
+		t, err := time.ParseInLocation(timestampFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		seq, _ := strconv.Atoi(suffix)
+
+		backup := Backup{Name: entry.Name(), Time: t, seq: seq}
+		files, _ := os.ReadDir(w.path(filepath.Join(backupDirName, entry.Name())))
+		for _, file := range files {
+			// skip temporary files left by an interrupted backup
+			if !strings.HasPrefix(file.Name(), ".") {
+				backup.Files = append(backup.Files, file.Name())
+			}
+		}
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].seq < backups[j].seq
+		}
+		return backups[i].Time.Before(backups[j].Time)
+	})
+
+	return backups, nil
+}
+
+func (w *Workspace) pruneBackups() error {
+	backups, err := w.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	for i, backup := range backups {
+		// the backup that was just taken is the newest, and never pruned
+		if !w.Config.Backups.expired(i, len(backups), backup.Time) {
+			continue
+		}
+		err := os.RemoveAll(w.path(filepath.Join(backupDirName, backup.Name)))
+		if err != nil {
+			log.Println("Failed to prune backup", backup.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// parseAge parses a duration, additionally accepting a number of days such
+// as "30d".
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(age)
+}
+
+// Restore puts the state files of the named backup back into the workspace,
+// and removes the state files the backup does not have, so that the
+// workspace is left as it was when the backup was taken. The current state
+// files are backed up first, so a restore can itself be undone.
+func (w *Workspace) Restore(name string) error {
+	backups, err := w.ListBackups()
+	if err != nil {
+		return err
+	}
+	var backup *Backup
+	for _, b := range backups {
+		if b.Name == name {
+			backup = &b
+			break
+		}
+	}
+	if backup == nil {
+		return fmt.Errorf("no backup named %s", name)
+	}
+
+	dir := w.path(filepath.Join(backupDirName, backup.Name))
+	contents := make(map[string][]byte)
+	for _, file := range backup.Files {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return fmt.Errorf("failed to read %s from backup %s: %w", file, name, err)
+		}
+		contents[file] = data
+	}
+
+	err = w.Backup()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range backup.Files {
+		err := WriteFileAtomic(w.path(file), contents[file], 0666)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file, err)
+		}
+		log.Println("Restored", file, "from backup", name)
+	}
+	for _, file := range stateFiles() {
+		if contains(backup.Files, file) || !IsValidFile(w.path(file)) {
+			continue
+		}
+		err := os.Remove(w.path(file))
+		if err != nil {
+			return fmt.Errorf("failed to remove %s, which backup %s does not have: %w", file, name, err)
+		}
+		log.Println("Removed", file, "which backup", name, "does not have")
+	}
+
+	return nil
+}
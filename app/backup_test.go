@@ -0,0 +1,61 @@
+package app
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	w, err := OpenWorkspace(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, data string) {
+		t.Helper()
+		if err := os.WriteFile(w.path(name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a backup taken before aliases.json was first written
+	write(stateFileName, "old state")
+	write("package.json", "old manifest")
+	if err := w.Backup(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := w.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups %v: %v", backups, err)
+	}
+	write(stateFileName, "new state")
+	write("package.json", "new manifest")
+	write(aliasMapFileName, "new aliases")
+
+	err = w.Restore(backups[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{stateFileName: "old state", "package.json": "old manifest"}
+	for _, name := range stateFiles() {
+		data, err := os.ReadFile(w.path(name))
+		if _, restored := want[name]; !restored {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s is left behind after the restore", name)
+			}
+			continue
+		}
+		if err != nil || string(data) != want[name] {
+			t.Errorf("%s has %q (%v), want %q", name, data, err, want[name])
+		}
+	}
+
+	// the restore itself can be undone
+	backups, err = w.ListBackups()
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("backups %v: %v", backups, err)
+	}
+	if !contains(backups[1].Files, aliasMapFileName) {
+		t.Errorf("the backup taken before restoring has %v, want %s too", backups[1].Files, aliasMapFileName)
+	}
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Sections of a manifest pacman reads dependencies from.
+const (
+	SectionDependencies    = "dependencies"
+	SectionDevDependencies = "devDependencies"
+)
+
+// AddDependency adds name at spec to the dependencies of repo's manifest, or
+// to its devDependencies with dev. A dependency already in the section gets
+// spec instead of its current one; one in the other section is moved, as npm
+// install does. The result has no changes when the manifest already has
+// name at spec. Like UpdateManifest, it only rewrites the bytes that change.
+func AddDependency(repo string, manifest []byte, name string, spec string, dev bool) (*UpdateResult, error) {
+	var pkgDeps PackageDependencies
+	err := json.Unmarshal(manifest, &pkgDeps)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing package.json for %s: %w", repo, err)
+	}
+
+	section, other := SectionDependencies, SectionDevDependencies
+	deps, otherDeps := pkgDeps.Dependencies, pkgDeps.DevDependencies
+	if dev {
+		section, other = other, section
+		deps, otherDeps = otherDeps, deps
+	}
+
+	result := &UpdateResult{Repo: repo, Manifest: manifest}
+	change := DependencyChange{Package: name, Section: section, To: spec}
+	if current, exists := deps[name]; exists {
+		if current == spec {
+			return result, nil
+		}
+		result.Manifest, err = setStringMember(manifest, spec, section, name)
+		if err != nil {
+			return nil, fmt.Errorf("error updating package.json for %s: %w", repo, err)
+		}
+		change.From, change.Reason = current, "spec set by add"
+		result.Changes = append(result.Changes, change)
+		return result, nil
+	}
+
+	change.Reason = "added"
+	if current, exists := otherDeps[name]; exists {
+		result.Manifest, err = removeMember(result.Manifest, other, name)
+		if err != nil {
+			return nil, fmt.Errorf("error updating package.json for %s: %w", repo, err)
+		}
+		change.From, change.Reason = current, "moved from "+other
+	}
+	result.Manifest, err = insertDependency(result.Manifest, section, name, spec)
+	if err != nil {
+		return nil, fmt.Errorf("error updating package.json for %s: %w", repo, err)
+	}
+	result.Changes = append(result.Changes, change)
+
+	return result, nil
+}
+
+// insertDependency adds name at spec to section of manifest, adding the
+// section first if manifest has none.
+func insertDependency(manifest []byte, section string, name string, spec string) ([]byte, error) {
+	_, found, err := findMember(manifest, section)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		manifest, err = addMember(manifest, section, []byte("{}"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	encoded, err := encodeJSONString(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return addMember(manifest, name, encoded, section)
+}
+
+// RemoveDependency removes name from the dependencies and devDependencies of
+// repo's manifest. The result has no changes when the manifest does not
+// depend on name. Like UpdateManifest, it only rewrites the bytes that
+// change.
+func RemoveDependency(repo string, manifest []byte, name string) (*UpdateResult, error) {
+	var pkgDeps PackageDependencies
+	err := json.Unmarshal(manifest, &pkgDeps)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing package.json for %s: %w", repo, err)
+	}
+
+	result := &UpdateResult{Repo: repo, Manifest: manifest}
+	for _, section := range []string{SectionDependencies, SectionDevDependencies} {
+		deps := pkgDeps.Dependencies
+		if section == SectionDevDependencies {
+			deps = pkgDeps.DevDependencies
+		}
+		current, exists := deps[name]
+		if !exists {
+			continue
+		}
+		result.Manifest, err = removeMember(result.Manifest, section, name)
+		if err != nil {
+			return nil, fmt.Errorf("error updating package.json for %s: %w", repo, err)
+		}
+		result.Changes = append(result.Changes, DependencyChange{Package: name, Section: section, From: current, Reason: "removed"})
+	}
+
+	return result, nil
+}
+
+// ApplyChanges records in inv the changes made to the manifest of repo, so
+// that the declared specs and the versions in use match the manifest without
+// parsing it again. A change without To is a removal, and one with the same
+// From and To a move between sections, which keeps the version inv has.
+func (inv *Inventory) ApplyChanges(repo string, changes []DependencyChange) {
+	info := inv.Repos[repo]
+	info.Dependencies = copyMap(info.Dependencies)
+	info.DevDependencies = copyMap(info.DevDependencies)
+
+	for _, change := range changes {
+		delete(info.Dependencies, change.Package)
+		delete(info.DevDependencies, change.Package)
+		dev := change.Section == SectionDevDependencies
+		switch {
+		case change.To == "":
+		case dev:
+			info.DevDependencies[change.Package] = change.To
+		default:
+			info.Dependencies[change.Package] = change.To
+		}
+		if change.From == change.To {
+			continue
+		}
+
+		inv.removeRepo(change.Package, repo)
+		if change.To != "" {
+			inv.Packages = transform(inv.Packages, repo, map[string]string{change.Package: change.To}, dev)
+		}
+	}
+	inv.Repos[repo] = info
+}
+
+// removeRepo removes repo from every version of pkg, and pkg from inv when
+// no repo uses it any more.
+func (inv *Inventory) removeRepo(pkg string, repo string) {
+	p, exists := inv.Packages[pkg]
+	if !exists {
+		return
+	}
+	for version, repos := range p.Versions {
+		var kept []string
+		for _, r := range repos {
+			if r != repo {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.Versions, version)
+		} else {
+			p.Versions[version] = kept
+		}
+	}
+	if len(p.Versions) == 0 {
+		delete(inv.Packages, pkg)
+	}
+}
+
+func copyMap(m map[string]string) map[string]string {
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+
+	return copied
+}
@@ -0,0 +1,146 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddDependency(t *testing.T) {
+	manifest := "{\n  \"name\": \"a\",\n  \"dependencies\": {\n    \"b\": \"^1.0.0\",\n    \"d\": \"^1.0.0\"\n  },\n  \"devDependencies\": {\n    \"e\": \"^1.0.0\"\n  }\n}\n"
+	tests := []struct {
+		name string
+		data string
+		pkg  string
+		spec string
+		dev  bool
+		want string
+		// change is the change reported, if there is one.
+		change *DependencyChange
+	}{
+		{
+			name:   "added",
+			data:   manifest,
+			pkg:    "c",
+			spec:   "^2.0.0",
+			want:   "{\n  \"name\": \"a\",\n  \"dependencies\": {\n    \"b\": \"^1.0.0\",\n    \"c\": \"^2.0.0\",\n    \"d\": \"^1.0.0\"\n  },\n  \"devDependencies\": {\n    \"e\": \"^1.0.0\"\n  }\n}\n",
+			change: &DependencyChange{Package: "c", Section: "dependencies", To: "^2.0.0", Reason: "added"},
+		},
+		{
+			name:   "spec set",
+			data:   manifest,
+			pkg:    "b",
+			spec:   "^1.2.0",
+			want:   "{\n  \"name\": \"a\",\n  \"dependencies\": {\n    \"b\": \"^1.2.0\",\n    \"d\": \"^1.0.0\"\n  },\n  \"devDependencies\": {\n    \"e\": \"^1.0.0\"\n  }\n}\n",
+			change: &DependencyChange{Package: "b", Section: "dependencies", From: "^1.0.0", To: "^1.2.0", Reason: "spec set by add"},
+		},
+		{
+			name: "already there",
+			data: manifest,
+			pkg:  "b",
+			spec: "^1.0.0",
+			want: manifest,
+		},
+		{
+			name:   "moved",
+			data:   manifest,
+			pkg:    "e",
+			spec:   "^1.0.0",
+			want:   "{\n  \"name\": \"a\",\n  \"dependencies\": {\n    \"b\": \"^1.0.0\",\n    \"d\": \"^1.0.0\",\n    \"e\": \"^1.0.0\"\n  },\n  \"devDependencies\": {}\n}\n",
+			change: &DependencyChange{Package: "e", Section: "dependencies", From: "^1.0.0", To: "^1.0.0", Reason: "moved from devDependencies"},
+		},
+		{
+			name: "new section",
+			data: "{\n  \"name\": \"a\"\n}\n",
+			pkg:  "@types/node",
+			spec: "^18.0.0",
+			dev:  true,
+			// the keys are sorted, so the section goes in order
+			want:   "{\n  \"devDependencies\": {\n    \"@types/node\": \"^18.0.0\"\n  },\n  \"name\": \"a\"\n}\n",
+			change: &DependencyChange{Package: "@types/node", Section: "devDependencies", To: "^18.0.0", Reason: "added"},
+		},
+	}
+
+	for _, test := range tests {
+		result, err := AddDependency("a", []byte(test.data), test.pkg, test.spec, test.dev)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(result.Manifest) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, result.Manifest, test.want)
+		}
+		var want []DependencyChange
+		if test.change != nil {
+			want = []DependencyChange{*test.change}
+		}
+		if !reflect.DeepEqual(result.Changes, want) {
+			t.Errorf("%s: changes %+v, want %+v", test.name, result.Changes, want)
+		}
+	}
+}
+
+func TestRemoveDependency(t *testing.T) {
+	manifest := `{"dependencies": {"a": "^1.0.0", "b": "^1.0.0"}, "devDependencies": {"a": "^1.1.0"}}`
+	tests := []struct {
+		pkg     string
+		want    string
+		changes []DependencyChange
+	}{
+		{
+			pkg:  "a",
+			want: `{"dependencies": {"b": "^1.0.0"}, "devDependencies": {}}`,
+			changes: []DependencyChange{
+				{Package: "a", Section: "dependencies", From: "^1.0.0", Reason: "removed"},
+				{Package: "a", Section: "devDependencies", From: "^1.1.0", Reason: "removed"},
+			},
+		},
+		{
+			pkg:     "b",
+			want:    `{"dependencies": {"a": "^1.0.0"}, "devDependencies": {"a": "^1.1.0"}}`,
+			changes: []DependencyChange{{Package: "b", Section: "dependencies", From: "^1.0.0", Reason: "removed"}},
+		},
+		{pkg: "c", want: manifest},
+	}
+
+	for _, test := range tests {
+		result, err := RemoveDependency("a", []byte(manifest), test.pkg)
+		if err != nil {
+			t.Errorf("%s: %v", test.pkg, err)
+			continue
+		}
+		if string(result.Manifest) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.pkg, result.Manifest, test.want)
+		}
+		if !reflect.DeepEqual(result.Changes, test.changes) {
+			t.Errorf("%s: changes %+v, want %+v", test.pkg, result.Changes, test.changes)
+		}
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	inv := inventoryOf(t, map[string][]byte{
+		"a": []byte(`{"dependencies": {"dep": "1.0.0", "old": "1.0.0"}}`),
+		"b": []byte(`{"dependencies": {"dep": "1.0.0"}}`),
+	})
+	inv.ApplyChanges("a", []DependencyChange{
+		{Package: "dep", Section: "dependencies", From: "1.0.0", To: "1.1.0"},
+		{Package: "old", Section: "dependencies", From: "1.0.0"},
+		{Package: "new", Section: "devDependencies", To: "2.0.0"},
+	})
+
+	want := map[string]map[string][]string{
+		"dep": {"1.0.0": {"b"}, "1.1.0": {"a"}},
+		"new": {"2.0.0": {"a"}},
+	}
+	got := make(map[string]map[string][]string)
+	for name, pkg := range inv.Packages {
+		got[name] = pkg.Versions
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("packages %v, want %v", got, want)
+	}
+	info := inv.Repos["a"]
+	if spec, _ := inv.DeclaredSpec("a", "dep"); spec != "1.1.0" || info.DevDependencies["new"] != "2.0.0" || info.Dependencies["old"] != "" {
+		t.Errorf("repo a declares %v and %v", info.Dependencies, info.DevDependencies)
+	}
+}
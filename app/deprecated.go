@@ -0,0 +1,241 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// DeprecatedConfig is the deprecated section of the config file.
+type DeprecatedConfig struct {
+	// Fail makes the deprecated command fail when deprecated versions that
+	// are not allowed are in use, so that CI can catch them.
+	Fail bool `json:"fail"`
+	// Allow maps packages, or patterns such as "@types/*", to the reason
+	// their deprecated versions are accepted for now.
+	Allow map[string]string `json:"allow"`
+}
+
+// DeprecatedOptions selects what Deprecated reports.
+type DeprecatedOptions struct {
+	RegistryQuery
+	// Allow maps packages, or patterns, to the reason their deprecated
+	// versions are accepted. They are still reported, as allowed.
+	Allow map[string]string
+}
+
+func (opts DeprecatedOptions) validate() error {
+	for pattern := range opts.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+		}
+	}
+
+	return opts.RegistryQuery.validate()
+}
+
+// Deprecation is a deprecated version of a package that repos use.
+type Deprecation struct {
+	Package string   `json:"package"`
+	Version string   `json:"version"`
+	Message string   `json:"message"`
+	Repos   []string `json:"repos"`
+	// Latest is the latest version of the package, empty when it is
+	// deprecated too and upgrading does not help.
+	Latest string `json:"latest,omitempty"`
+	// Allowed is why the deprecation is accepted, if it is.
+	Allowed string `json:"allowed,omitempty"`
+}
+
+// PackageError is a package that could not be checked, and why.
+type PackageError struct {
+	Package string `json:"package"`
+	Error   string `json:"error"`
+}
+
+// UncheckedVersion is a version repos record for a package that does not
+// resolve to a published version, such as a git URL, so it could not be
+// checked.
+type UncheckedVersion struct {
+	Package string   `json:"package"`
+	Version string   `json:"version"`
+	Repos   []string `json:"repos"`
+}
+
+// DeprecationReport lists the deprecated versions in use.
+type DeprecationReport struct {
+	Deprecations []Deprecation      `json:"deprecations"`
+	Unchecked    []UncheckedVersion `json:"unchecked"`
+	Failed       []PackageError     `json:"failed"`
+}
+
+// Violations returns the deprecations that are not allowed.
+func (r *DeprecationReport) Violations() []Deprecation {
+	var violations []Deprecation
+	for _, deprecation := range r.Deprecations {
+		if deprecation.Allowed == "" {
+			violations = append(violations, deprecation)
+		}
+	}
+
+	return violations
+}
+
+// Check returns why the deprecated command fails, with fail: deprecations
+// that are not allowed were found, or packages could not be fetched.
+// Versions that could not be checked never fail it.
+func (r *DeprecationReport) Check(fail bool) error {
+	if !fail {
+		return nil
+	}
+	if violations := r.Violations(); len(violations) > 0 {
+		return fmt.Errorf("%d deprecated version(s) in use", len(violations))
+	}
+	if len(r.Failed) > 0 {
+		return fmt.Errorf("could not check %d package(s)", len(r.Failed))
+	}
+
+	return nil
+}
+
+// Deprecated finds the versions of packages used in inv that the registry,
+// as told by source, marks deprecated. Every version a repo records is
+// checked, resolved to a published version as Outdated does.
+func Deprecated(ctx context.Context, inv *Inventory, source PackumentSource, opts DeprecatedOptions) (*DeprecationReport, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := opts.versions(inv)
+	packuments, errs := fetchPackuments(ctx, source, sortedKeys(versions), opts.Jobs)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &DeprecationReport{Deprecations: []Deprecation{}, Unchecked: []UncheckedVersion{}, Failed: []PackageError{}}
+	for _, name := range sortedKeys(versions) {
+		if err := errs[name]; err != nil {
+			report.Failed = append(report.Failed, PackageError{name, err.Error()})
+			continue
+		}
+		packument := packuments[name]
+		latest := latestVersion(packument)
+
+		// the repos on each published version, and on each recorded
+		// version that resolves to none
+		repos := make(map[string][]string)
+		unresolved := make(map[string][]string)
+		for recorded, users := range versions[name] {
+			version, resolved := currentVersion(packument, recorded, "", latest)
+			for _, repo := range users {
+				switch {
+				case !resolved && !contains(unresolved[recorded], repo):
+					unresolved[recorded] = append(unresolved[recorded], repo)
+				case resolved && !contains(repos[version], repo):
+					repos[version] = append(repos[version], repo)
+				}
+			}
+		}
+		for _, recorded := range sortedKeys(unresolved) {
+			sort.Strings(unresolved[recorded])
+			report.Unchecked = append(report.Unchecked, UncheckedVersion{name, recorded, unresolved[recorded]})
+		}
+
+		upgrade := ""
+		if latest != nil && packument.Versions[latest.Original()].Deprecated == "" {
+			upgrade = latest.Original()
+		}
+		allowed := ""
+		if pattern, matched := matchPattern(opts.Allow, name); matched {
+			allowed = reasonOr(opts.Allow[pattern], "allowed")
+		}
+
+		for _, version := range sortedVersions(repos) {
+			message := packument.Versions[version].Deprecated
+			if message == "" {
+				continue
+			}
+			sort.Strings(repos[version])
+			report.Deprecations = append(report.Deprecations, Deprecation{
+				Package: name,
+				Version: version,
+				Message: message,
+				Repos:   repos[version],
+				Latest:  upgrade,
+				Allowed: allowed,
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// WriteDeprecationsJSON writes report as indented JSON.
+func WriteDeprecationsJSON(w io.Writer, report *DeprecationReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// WriteDeprecationsText writes report in a human readable form: the
+// deprecated versions in use with the registry's message and the repos
+// using them, then the allowed ones and the packages and versions that
+// could not be checked.
+func WriteDeprecationsText(w io.Writer, report *DeprecationReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var allowed []Deprecation
+	violations := report.Violations()
+	for _, deprecation := range report.Deprecations {
+		if deprecation.Allowed != "" {
+			allowed = append(allowed, deprecation)
+		}
+	}
+
+	if len(report.Deprecations) == 0 {
+		fmt.Fprintln(tw, "No deprecated versions in use")
+	} else if len(violations) > 0 {
+		fmt.Fprintln(tw, "Deprecated versions in use:")
+	}
+	for _, deprecation := range violations {
+		fmt.Fprintf(tw, "\n%s@%s: %s\n", deprecation.Package, deprecation.Version, deprecation.Message)
+		fmt.Fprintf(tw, "  used by: %s\n", strings.Join(deprecation.Repos, ", "))
+		if deprecation.Latest == "" {
+			fmt.Fprintln(tw, "  latest: deprecated too")
+		} else {
+			fmt.Fprintf(tw, "  latest: %s\n", deprecation.Latest)
+		}
+	}
+
+	if len(allowed) > 0 {
+		if len(violations) > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintln(tw, "Allowed:")
+		for _, deprecation := range allowed {
+			fmt.Fprintf(tw, "  %s@%s\t%s\t%s\n", deprecation.Package, deprecation.Version,
+				strings.Join(deprecation.Repos, ", "), deprecation.Allowed)
+		}
+	}
+	if len(report.Failed)+len(report.Unchecked) > 0 {
+		fmt.Fprintln(tw, "\nCould not check:")
+		for _, failed := range report.Failed {
+			fmt.Fprintf(tw, "  %s\t%s\n", failed.Package, failed.Error)
+		}
+		for _, unchecked := range report.Unchecked {
+			fmt.Fprintf(tw, "  %s@%s\tno published version matches; used by %s\n", unchecked.Package, unchecked.Version,
+				strings.Join(unchecked.Repos, ", "))
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// deprecatedManifests has repo a use dep 1.x, which resolves to the
+// deprecated 1.5.0, and request, whose latest version is deprecated too; b
+// use dep 1.0.0 and, in devDependencies, 1.5.0; and c use dep from git and
+// private, which the registry does not know.
+var deprecatedManifests = map[string][]byte{
+	"a": []byte(`{"dependencies": {"dep": "1.x", "request": "2.88.2"}}`),
+	"b": []byte(`{"dependencies": {"dep": "1.0.0"}, "devDependencies": {"dep": "^1.5.0"}}`),
+	"c": []byte(`{"dependencies": {"dep": "github:org/dep", "private": "1.0.0"}}`),
+}
+
+var deprecatedPackuments = packuments{
+	"dep": {
+		DistTags: map[string]string{"latest": "2.0.0"},
+		Versions: map[string]PackumentVersion{"1.0.0": {}, "1.5.0": {Deprecated: "use 2"}, "2.0.0": {}},
+	},
+	"request": {
+		DistTags: map[string]string{"latest": "2.88.2"},
+		Versions: map[string]PackumentVersion{"2.88.2": {Deprecated: "request has been deprecated"}},
+	},
+}
+
+func TestDeprecated(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow map[string]string
+		want  []Deprecation
+		// text is part of the text output.
+		text string
+		// fail is part of the error Check returns with fail, empty if none.
+		fail string
+	}{
+		{
+			name: "reported",
+			want: []Deprecation{
+				{Package: "dep", Version: "1.5.0", Message: "use 2", Repos: []string{"a", "b"}, Latest: "2.0.0"},
+				{Package: "request", Version: "2.88.2", Message: "request has been deprecated", Repos: []string{"a"}},
+			},
+			text: "request@2.88.2: request has been deprecated\n  used by: a\n  latest: deprecated too\n",
+			fail: "2 deprecated version(s) in use",
+		},
+		{
+			name:  "allowed",
+			allow: map[string]string{"*": "", "request": "being replaced"},
+			want: []Deprecation{
+				{Package: "dep", Version: "1.5.0", Message: "use 2", Repos: []string{"a", "b"}, Latest: "2.0.0", Allowed: "allowed"},
+				{Package: "request", Version: "2.88.2", Message: "request has been deprecated", Repos: []string{"a"}, Allowed: "being replaced"},
+			},
+			text: "Allowed:\n  dep@1.5.0       a, b  allowed\n  request@2.88.2  a     being replaced\n",
+			fail: "could not check 1 package(s)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inv := inventoryOf(t, deprecatedManifests)
+			report, err := Deprecated(context.Background(), inv, deprecatedPackuments, DeprecatedOptions{Allow: test.allow})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Deprecations, test.want) {
+				t.Errorf("deprecations %+v, want %+v", report.Deprecations, test.want)
+			}
+			unchecked := []UncheckedVersion{{Package: "dep", Version: "github:org/dep", Repos: []string{"c"}}}
+			if !reflect.DeepEqual(report.Unchecked, unchecked) {
+				t.Errorf("unchecked %+v, want %+v", report.Unchecked, unchecked)
+			}
+			if len(report.Failed) != 1 || report.Failed[0].Package != "private" {
+				t.Errorf("failed %+v, want private", report.Failed)
+			}
+
+			var buf bytes.Buffer
+			if err := WriteDeprecationsText(&buf, report); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), test.text) {
+				t.Errorf("text output\n%s\ndoes not contain\n%s", buf.String(), test.text)
+			}
+
+			if err := report.Check(false); err != nil {
+				t.Errorf("Check without fail returned %v", err)
+			}
+			if err := report.Check(true); err == nil || !strings.Contains(err.Error(), test.fail) {
+				t.Errorf("Check with fail returned %v, want an error containing %q", err, test.fail)
+			}
+		})
+	}
+}
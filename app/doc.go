@@ -0,0 +1,22 @@
+// Package app is pacman as a library.
+//
+// BuildInventory reads manifests from a Source, such as a DirSource over an
+// fs.FS or a GitHubSource, and returns the Inventory of every dependency in
+// use. Unify and UpdateManifest work on an inventory and return their results
+// instead of writing anything. All of them take a context and report failures
+// as errors.
+//
+// File output is left to the caller. A Workspace reads and writes the files
+// the pacman command uses (the state file, the aggregate package.json,
+// snapshots and backups) for callers that want the same layout:
+//
+//	inv, err := app.BuildInventory(ctx, &app.DirSource{FS: os.DirFS("npm")})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := app.Unify(ctx, inv, app.UnifyOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	aggregate, aliases := app.RenderAggregate(result.Inventory, app.AggregateConfig{})
+package app
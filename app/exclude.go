@@ -0,0 +1,150 @@
+package app
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Exclusions are the packages and repos unify and update must leave alone,
+// each with the reason why, so that decisions already taken are not proposed
+// again.
+type Exclusions struct {
+	Holds  []Hold       `json:"holds"`
+	Ignore IgnoreConfig `json:"ignore"`
+}
+
+// Hold pins a package. Range limits the hold to the versions it matches, so
+// that "4.x" holds repos on mongoose 4 but lets those on 5 be unified. Repos
+// limits it to those repos. Either may be empty to hold every version or
+// every repo.
+type Hold struct {
+	Package string   `json:"package"`
+	Range   string   `json:"range,omitempty"`
+	Repos   []string `json:"repos,omitempty"`
+	Reason  string   `json:"reason"`
+}
+
+// IgnoreConfig maps packages, or patterns such as "@types/*", and repos to
+// the reason they are ignored.
+type IgnoreConfig struct {
+	Packages map[string]string `json:"packages"`
+	Repos    map[string]string `json:"repos"`
+}
+
+// Exclusion is a package, or a repo's use of a package, that was left alone.
+// Repo and Version are empty when the whole package was.
+type Exclusion struct {
+	Package string `json:"package"`
+	Repo    string `json:"repo,omitempty"`
+	Version string `json:"version,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+func (e Exclusions) validate() error {
+	for pattern := range e.Ignore.Packages {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+		}
+	}
+	for _, hold := range e.Holds {
+		if hold.Package == "" {
+			return fmt.Errorf("hold without a package")
+		}
+		if hold.Range == "" {
+			continue
+		}
+		if _, err := semver.NewConstraint(hold.Range); err != nil {
+			return fmt.Errorf("hold on %s: invalid range %q", hold.Package, hold.Range)
+		}
+	}
+
+	return nil
+}
+
+// ignoredPackage returns the reason package name is ignored, if it is.
+func (e Exclusions) ignoredPackage(name string) (string, bool) {
+	if pattern, matched := matchPattern(e.Ignore.Packages, name); matched {
+		return reasonOr(e.Ignore.Packages[pattern], "package is ignored"), true
+	}
+
+	return "", false
+}
+
+// heldRepo returns the reason repo's use of version of package name must not
+// change, if it must not.
+func (e Exclusions) heldRepo(name string, repo string, version string) (string, bool) {
+	if reason, exists := e.Ignore.Repos[repo]; exists {
+		return reasonOr(reason, "repo is ignored"), true
+	}
+
+	for _, hold := range e.Holds {
+		if hold.Package != name || (len(hold.Repos) > 0 && !contains(hold.Repos, repo)) {
+			continue
+		}
+		if hold.Range != "" {
+			c, err := semver.NewConstraint(hold.Range)
+			if err != nil {
+				continue
+			}
+			v, err := semver.NewVersion(version)
+			if err != nil || !c.Check(v) {
+				continue
+			}
+		}
+		return reasonOr(hold.Reason, "held"), true
+	}
+
+	return "", false
+}
+
+// matchPattern returns the key of patterns that matches name: name itself if
+// it is a key, or else the longest path.Match pattern matching it.
+func matchPattern(patterns map[string]string, name string) (string, bool) {
+	if _, exists := patterns[name]; exists {
+		return name, true
+	}
+
+	best := ""
+	for _, pattern := range sortedKeys(patterns) {
+		if matched, _ := path.Match(pattern, name); matched && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+
+	return best, best != ""
+}
+
+// matchesAny reports whether name matches one of patterns, as path.Match
+// does, or whether there are none.
+func matchesAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func reasonOr(reason string, fallback string) string {
+	if reason == "" {
+		return fallback
+	}
+
+	return reason
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GitConfig is the git section of the config file: how update commits the
+// changes in local checkouts. Branch and CommitMessage are text/template
+// templates executed with a TemplateData.
+type GitConfig struct {
+	// Base is the revision branches are created from. Empty means the
+	// commit checked out.
+	Base          string `json:"base"`
+	Branch        string `json:"branch"`
+	CommitMessage string `json:"commitMessage"`
+	// Push pushes each branch after committing.
+	Push bool `json:"push"`
+	// Remote is pushed to. Empty means origin.
+	Remote string `json:"remote"`
+}
+
+// BaseRevision returns the revision branches are created from.
+func (c GitConfig) BaseRevision() string {
+	if c.Base == "" {
+		return "HEAD"
+	}
+
+	return c.Base
+}
+
+// RemoteName returns the remote branches are pushed to.
+func (c GitConfig) RemoteName() string {
+	if c.Remote == "" {
+		return "origin"
+	}
+
+	return c.Remote
+}
+
+// Render returns the branch name and commit message for data.
+func (c GitConfig) Render(data TemplateData) (string, string, error) {
+	branch, err := executeTemplate("branch", c.Branch, DefaultBranch, data)
+	if err != nil {
+		return "", "", err
+	}
+	message, err := executeTemplate("commit message", c.CommitMessage, DefaultCommitMessage, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return branch, message, nil
+}
+
+// GitCheckout is a git working tree on disk.
+type GitCheckout struct {
+	Dir string
+}
+
+// CheckClean returns an error when Dir is not in a git working tree, or when
+// the tree has uncommitted changes to tracked files.
+func (g GitCheckout) CheckClean(ctx context.Context) error {
+	status, err := g.git(ctx, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		return fmt.Errorf("%s has uncommitted changes", g.Dir)
+	}
+
+	return nil
+}
+
+// CurrentBranch returns the branch checked out, or the commit when none is.
+func (g GitCheckout) CurrentBranch(ctx context.Context) (string, error) {
+	branch, err := g.git(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+	if err == nil && branch == "HEAD" {
+		branch, err = g.git(ctx, "rev-parse", "HEAD")
+	}
+
+	return branch, err
+}
+
+// Show returns the contents of the file path, relative to Dir, at rev.
+func (g GitCheckout) Show(ctx context.Context, rev string, path string) ([]byte, error) {
+	out, err := g.run(ctx, "show", rev+":./"+path)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Checkout checks out branch, created from base or reset to it if it exists.
+func (g GitCheckout) Checkout(ctx context.Context, branch string, base string) error {
+	_, err := g.git(ctx, "checkout", "-q", "-B", branch, base)
+
+	return err
+}
+
+// Switch checks out an existing branch or commit.
+func (g GitCheckout) Switch(ctx context.Context, rev string) error {
+	_, err := g.git(ctx, "checkout", "-q", rev)
+
+	return err
+}
+
+// Commit commits paths, relative to Dir, with message.
+func (g GitCheckout) Commit(ctx context.Context, message string, paths ...string) error {
+	_, err := g.git(ctx, append([]string{"add", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	_, err = g.git(ctx, "commit", "-q", "-m", message)
+
+	return err
+}
+
+// Unstage removes the changes to paths, relative to Dir, from the index.
+func (g GitCheckout) Unstage(ctx context.Context, paths ...string) error {
+	_, err := g.git(ctx, append([]string{"reset", "-q", "--"}, paths...)...)
+
+	return err
+}
+
+// Push pushes branch to remote. A branch left by an earlier run is
+// overwritten, unless it has changed on the remote since it was fetched.
+func (g GitCheckout) Push(ctx context.Context, remote string, branch string) error {
+	_, err := g.git(ctx, "push", "-q", "--force-with-lease", "-u", remote, branch)
+
+	return err
+}
+
+func (g GitCheckout) git(ctx context.Context, args ...string) (string, error) {
+	out, err := g.run(ctx, args...)
+
+	return strings.TrimSpace(string(out)), err
+}
+
+func (g GitCheckout) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.Dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", args[0], err, outputTail(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// gitRepo turns each checkout in root into a git repo on branch main, with
+// everything in it committed.
+func gitRepo(t *testing.T, root string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		dir := filepath.Join(root, entry.Name())
+		for _, args := range [][]string{
+			{"init", "-q", "-b", "main"},
+			{"config", "user.name", "Test"},
+			{"config", "user.email", "test@example.com"},
+			{"add", "."},
+			{"commit", "-q", "-m", "Initial commit"},
+		} {
+			if _, err := (GitCheckout{Dir: dir}).git(context.Background(), args...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// failCommits makes every commit in dir fail, with a pre-commit hook.
+func failCommits(t *testing.T, dir string) {
+	t.Helper()
+	err := os.WriteFile(filepath.Join(dir, ".git", "hooks", "pre-commit"), []byte("#!/bin/sh\nexit 1\n"), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitCheckout(t *testing.T) {
+	ctx := context.Background()
+	root := checkouts(t, map[string]string{"a": "^1.0.0"})
+	gitRepo(t, root)
+	checkout := GitCheckout{Dir: filepath.Join(root, "a")}
+	manifestPath := filepath.Join(checkout.Dir, "package.json")
+
+	if err := checkout.CheckClean(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkout.Checkout(ctx, "update", "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if branch, err := checkout.CurrentBranch(ctx); err != nil || branch != "update" {
+		t.Fatalf("current branch %q, %v", branch, err)
+	}
+
+	if err := os.WriteFile(manifestPath, manifestWith("^2.0.0"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkout.CheckClean(ctx); err == nil {
+		t.Error("changed package.json is clean")
+	}
+	if err := checkout.Commit(ctx, "Update dep", "package.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkout.CheckClean(ctx); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		rev  string
+		want string
+	}{
+		{"main", "^1.0.0"},
+		{"update", "^2.0.0"},
+	}
+	for _, test := range tests {
+		data, err := checkout.Show(ctx, test.rev, "package.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(manifestWith(test.want)) {
+			t.Errorf("%s has\n%s", test.rev, data)
+		}
+	}
+
+	if err := checkout.Switch(ctx, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if branch, err := checkout.CurrentBranch(ctx); err != nil || branch != "main" {
+		t.Errorf("current branch %q, %v", branch, err)
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(manifestWith("^1.0.0")) {
+		t.Errorf("main has\n%s", data)
+	}
+}
+
+func TestStartBranch(t *testing.T) {
+	ctx := context.Background()
+	root := checkouts(t, map[string]string{"a": "^1.0.0"})
+	gitRepo(t, root)
+	checkout := GitCheckout{Dir: filepath.Join(root, "a")}
+
+	target := &RepoUpdate{Repo: "a", Dir: checkout.Dir, Result: &UpdateResult{}}
+	previous, err := startBranch(ctx, checkout, target, GitConfig{Branch: "update-{{.Repo}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "main" {
+		t.Errorf("previous %q, want main", previous)
+	}
+	if target.Branch != "update-a" {
+		t.Errorf("branch %q, want update-a", target.Branch)
+	}
+	if branch, err := checkout.CurrentBranch(ctx); err != nil || branch != "update-a" {
+		t.Errorf("current branch %q, %v", branch, err)
+	}
+}
+
+func TestCommitUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		failCommit bool
+		push       bool
+		wantBranch string
+		wantSpec   string
+		wantPushed bool
+	}{
+		{name: "committed", wantBranch: DefaultBranch, wantSpec: "^4.17.21"},
+		{name: "pushed", push: true, wantBranch: DefaultBranch, wantSpec: "^4.17.21", wantPushed: true},
+		{name: "commit fails", failCommit: true, wantBranch: "main", wantSpec: "^4.17.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			root := checkouts(t, map[string]string{"a": "^4.17.1", "b": "^4.17.21"})
+			gitRepo(t, root)
+			checkout := GitCheckout{Dir: filepath.Join(root, "a")}
+			if test.failCommit {
+				failCommits(t, checkout.Dir)
+			}
+			if test.push {
+				remote := t.TempDir()
+				if _, err := (GitCheckout{Dir: remote}).git(ctx, "init", "-q", "--bare"); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := checkout.git(ctx, "remote", "add", "origin", remote); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			inv, err := BuildInventory(ctx, NewDirSource(root))
+			if err != nil {
+				t.Fatal(err)
+			}
+			moveTo(inv, "dep", "4.17.21")
+			targets, err := SelectRepos(inv, "", []string{"a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			UpdateRepos(ctx, inv, targets, RepoUpdateOptions{Git: &GitConfig{Push: test.push}})
+
+			target := targets[0]
+			if (target.Err != nil) != test.failCommit {
+				t.Errorf("error %v", target.Err)
+			}
+			if target.Pushed != test.wantPushed {
+				t.Errorf("pushed %v, want %v", target.Pushed, test.wantPushed)
+			}
+			if branch, err := checkout.CurrentBranch(ctx); err != nil || branch != test.wantBranch {
+				t.Errorf("current branch %q, %v, want %s", branch, err, test.wantBranch)
+			}
+			if err := checkout.CheckClean(ctx); err != nil {
+				t.Error(err)
+			}
+			data, err := os.ReadFile(filepath.Join(checkout.Dir, "package.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != string(manifestWith(test.wantSpec)) {
+				t.Errorf("package.json has\n%s", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sourceExtensions are the files ScanImports reads.
+var sourceExtensions = map[string]bool{
+	".js": true, ".jsx": true, ".mjs": true, ".cjs": true,
+	".ts": true, ".tsx": true, ".mts": true, ".cts": true,
+}
+
+// skippedDirs are never scanned: dependencies, build output and VCS data.
+var skippedDirs = map[string]bool{
+	"node_modules": true, ".git": true, "dist": true, "build": true, "coverage": true,
+}
+
+// devDirs hold tests and tooling rather than code that runs in production.
+var devDirs = map[string]bool{
+	"test": true, "tests": true, "__tests__": true, "__mocks__": true,
+	"spec": true, "e2e": true, "scripts": true, "tools": true,
+}
+
+// importPattern matches the module specifier of require calls, dynamic
+// imports, import declarations and re-exports.
+var importPattern = regexp.MustCompile(`(?:require\s*\(\s*|import\s*\(\s*|\bfrom\s+|\bimport\s+)['"]([^'"\s]+)['"]`)
+
+// ScanImports reads the JavaScript and TypeScript sources of the repo checked
+// out in dir and returns the packages they import, mapped to whether any
+// file that runs in production imports them. Files in test and tooling
+// directories, test files such as *.test.js and *.spec.ts, and config files
+// such as webpack.config.js and .eslintrc.js only count as development use.
+func ScanImports(dir string) (map[string]bool, error) {
+	imports := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && skippedDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !sourceExtensions[filepath.Ext(path)] {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		runtime := !isDevFile(rel)
+		for _, match := range importPattern.FindAllSubmatch(data, -1) {
+			name, ok := packageName(string(match[1]))
+			if ok {
+				imports[name] = imports[name] || runtime
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imports, nil
+}
+
+// isDevFile reports whether the file at path, relative to the repo, is a
+// test, tooling or config file.
+func isDevFile(path string) bool {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for _, dir := range parts[:len(parts)-1] {
+		if devDirs[dir] {
+			return true
+		}
+	}
+
+	name := parts[len(parts)-1]
+	for _, marker := range []string{".test.", ".spec.", ".stories.", ".config."} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "gulpfile.") || strings.HasPrefix(name, "Gruntfile.")
+}
+
+// packageName returns the package a module specifier refers to, such as
+// lodash for lodash/fp or @babel/core for @babel/core/lib/index, and false
+// for relative paths, absolute paths and node built-ins.
+func packageName(specifier string) (string, bool) {
+	if strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/") || strings.HasPrefix(specifier, "node:") {
+		return "", false
+	}
+
+	parts := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") {
+		if len(parts) < 2 {
+			return "", false
+		}
+		return parts[0] + "/" + parts[1], true
+	}
+
+	return parts[0], true
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanImports(t *testing.T) {
+	files := map[string]string{
+		"src/index.js":         "import React from 'react'\nconst fp = require(\"lodash/fp\")\nexport { transform } from '@babel/core/lib/index'\nimport('./lazy')\n",
+		"src/index.test.js":    "import { render } from '@testing-library/react'\nimport React from 'react'\n",
+		"src/files.ts":         "import fs from 'node:fs'\nimport config from '/etc/config'\nimport '../styles'\n",
+		"scripts/build.mjs":    "import { build } from 'esbuild'\n",
+		"webpack.config.js":    "module.exports = require('webpack-merge')\n",
+		".eslintrc.js":         "require('eslint-plugin-react')\n",
+		"node_modules/x/x.js":  "require('in-node-modules')\n",
+		"dist/bundle.js":       "require('in-dist')\n",
+		"README.md":            "require('in-readme')\n",
+		"lib/tests/helpers.js": "const sinon = require('sinon')\n",
+	}
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]bool{
+		"react":                  true,
+		"lodash":                 true,
+		"@babel/core":            true,
+		"@testing-library/react": false,
+		"esbuild":                false,
+		"webpack-merge":          false,
+		"eslint-plugin-react":    false,
+		"sinon":                  false,
+	}
+	got, err := ScanImports(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanImports() = %v, want %v", got, want)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	tests := []struct {
+		specifier string
+		want      string
+	}{
+		{"lodash", "lodash"},
+		{"lodash/fp", "lodash"},
+		{"@babel/core", "@babel/core"},
+		{"@babel/core/lib/index", "@babel/core"},
+		{"@babel", ""},
+		{"./local", ""},
+		{"../up", ""},
+		{"/abs", ""},
+		{"node:fs", ""},
+	}
+	for _, test := range tests {
+		got, ok := packageName(test.specifier)
+		if got != test.want || ok != (test.want != "") {
+			t.Errorf("packageName(%q) = %q, %t, want %q", test.specifier, got, ok, test.want)
+		}
+	}
+}
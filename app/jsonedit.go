@@ -0,0 +1,361 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The manifest editing functions change package.json files in place. Rather
+// than decoding and re-encoding the document, which loses key order,
+// indentation and line endings, they locate the bytes of the value to change
+// and replace only those, so that a diff of the file shows just the edited
+// lines.
+
+// jsonMember is a member of a JSON object, located by byte offsets.
+type jsonMember struct {
+	Key        string
+	KeyStart   int
+	KeyEnd     int
+	ValueStart int
+	ValueEnd   int
+}
+
+// jsonScanner walks a JSON document without decoding it.
+type jsonScanner struct {
+	data []byte
+	pos  int
+}
+
+func (s *jsonScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", s.pos, fmt.Sprintf(format, args...))
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) expect(c byte) error {
+	s.skipSpace()
+	if s.pos >= len(s.data) || s.data[s.pos] != c {
+		return s.errorf("expected %q", c)
+	}
+	s.pos++
+
+	return nil
+}
+
+// skipString moves past the string starting at the current position.
+func (s *jsonScanner) skipString() error {
+	if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+		return s.errorf("expected a string")
+	}
+	for s.pos++; s.pos < len(s.data); s.pos++ {
+		switch s.data[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			return nil
+		}
+	}
+
+	return s.errorf("unterminated string")
+}
+
+// skipValue moves past the value starting at the current position.
+func (s *jsonScanner) skipValue() error {
+	s.skipSpace()
+	if s.pos >= len(s.data) {
+		return s.errorf("expected a value")
+	}
+
+	switch s.data[s.pos] {
+	case '"':
+		return s.skipString()
+	case '{', '[':
+		// strings are skipped whole, so brackets inside them do not count
+		depth := 0
+		for s.pos < len(s.data) {
+			switch s.data[s.pos] {
+			case '"':
+				if err := s.skipString(); err != nil {
+					return err
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			s.pos++
+			if depth == 0 {
+				return nil
+			}
+		}
+		return s.errorf("unterminated object or array")
+	default:
+		start := s.pos
+		for s.pos < len(s.data) && bytes.IndexByte([]byte(",}] \t\r\n"), s.data[s.pos]) < 0 {
+			s.pos++
+		}
+		if s.pos == start {
+			return s.errorf("unexpected %q", s.data[s.pos])
+		}
+		return nil
+	}
+}
+
+// objectMembers returns the members of the object starting at offset start
+// and the offset just past its closing brace.
+func objectMembers(data []byte, start int) ([]jsonMember, int, error) {
+	s := &jsonScanner{data: data, pos: start}
+	if err := s.expect('{'); err != nil {
+		return nil, 0, err
+	}
+
+	var members []jsonMember
+	s.skipSpace()
+	if s.pos < len(data) && data[s.pos] == '}' {
+		return members, s.pos + 1, nil
+	}
+	for {
+		s.skipSpace()
+		member := jsonMember{KeyStart: s.pos}
+		if err := s.skipString(); err != nil {
+			return nil, 0, err
+		}
+		member.KeyEnd = s.pos
+		if err := json.Unmarshal(data[member.KeyStart:s.pos], &member.Key); err != nil {
+			return nil, 0, s.errorf("invalid key")
+		}
+		if err := s.expect(':'); err != nil {
+			return nil, 0, err
+		}
+		s.skipSpace()
+		member.ValueStart = s.pos
+		if err := s.skipValue(); err != nil {
+			return nil, 0, err
+		}
+		member.ValueEnd = s.pos
+		members = append(members, member)
+
+		s.skipSpace()
+		if s.pos >= len(data) {
+			return nil, 0, s.errorf("unterminated object")
+		}
+		if data[s.pos] == '}' {
+			return members, s.pos + 1, nil
+		}
+		if err := s.expect(','); err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// findMember returns the member at path, a list of keys leading from the
+// top-level object, and false if there is none.
+func findMember(data []byte, path ...string) (jsonMember, bool, error) {
+	s := &jsonScanner{data: data}
+	s.skipSpace()
+	member := jsonMember{ValueStart: s.pos}
+
+	for _, key := range path {
+		members, _, err := objectMembers(data, member.ValueStart)
+		if err != nil {
+			return jsonMember{}, false, err
+		}
+		found := false
+		for _, m := range members {
+			// like encoding/json, the last of duplicate keys wins
+			if m.Key == key {
+				member, found = m, true
+			}
+		}
+		if !found {
+			return jsonMember{}, false, nil
+		}
+	}
+
+	return member, true, nil
+}
+
+// setStringMember replaces the value of the existing member at path with the
+// string value and leaves every other byte of data as it is.
+func setStringMember(data []byte, value string, path ...string) ([]byte, error) {
+	member, found, err := findMember(data, path...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s not found", strings.Join(path, "."))
+	}
+
+	encoded, err := encodeJSONString(value)
+	if err != nil {
+		return nil, err
+	}
+	return splice(data, member.ValueStart, member.ValueEnd, string(encoded)), nil
+}
+
+// addMember adds a member with key and the raw JSON value to the object at
+// path. Members are indented like their siblings and, when the keys of the
+// object are sorted, inserted in order, as npm does; otherwise they are
+// appended. The object must not have a member named key already.
+func addMember(data []byte, key string, value []byte, path ...string) ([]byte, error) {
+	object, found, err := findMember(data, path...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s not found", strings.Join(path, "."))
+	}
+	members, end, err := objectMembers(data, object.ValueStart)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedKey, err := encodeJSONString(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		entry := string(encodedKey) + ": " + string(value)
+		unit := indentUnit(data)
+		if unit == "" {
+			return splice(data, object.ValueStart, end, "{"+entry+"}"), nil
+		}
+		indent := lineIndent(data, object.ValueStart)
+		nl := newline(data)
+		return splice(data, object.ValueStart, end, "{"+nl+indent+unit+entry+nl+indent+"}"), nil
+	}
+
+	// copy the layout of the existing members: what separates them from
+	// each other, or the first from the brace, and keys from values
+	sep := string(data[object.ValueStart+1 : members[0].KeyStart])
+	colon := string(data[members[0].KeyEnd:members[0].ValueStart])
+	switch {
+	case len(members) > 1:
+		between := data[members[0].ValueEnd:members[1].KeyStart]
+		sep = string(between[bytes.IndexByte(between, ',')+1:])
+	case !strings.Contains(sep, "\n") && strings.Contains(colon, " "):
+		// a lone member on one line, such as {"a": "1.0.0"}, has nothing
+		// to copy; space it like its key and value
+		sep = " "
+	}
+	entry := string(encodedKey) + colon + string(value)
+	i := len(members)
+	if sort.SliceIsSorted(members, func(a, b int) bool { return members[a].Key < members[b].Key }) {
+		i = sort.Search(len(members), func(j int) bool { return members[j].Key > key })
+	}
+	if i < len(members) {
+		return splice(data, members[i].KeyStart, members[i].KeyStart, entry+","+sep), nil
+	}
+	last := members[len(members)-1]
+
+	return splice(data, last.ValueEnd, last.ValueEnd, ","+sep+entry), nil
+}
+
+// removeMember removes the member at path, with the comma and whitespace
+// separating it from its siblings. An object left empty becomes {}.
+func removeMember(data []byte, path ...string) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the top-level object")
+	}
+	object, found, err := findMember(data, path[:len(path)-1]...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s not found", strings.Join(path, "."))
+	}
+	members, end, err := objectMembers(data, object.ValueStart)
+	if err != nil {
+		return nil, err
+	}
+
+	key := path[len(path)-1]
+	i := -1
+	for j, member := range members {
+		if member.Key == key {
+			i = j
+		}
+	}
+	switch {
+	case i < 0:
+		return nil, fmt.Errorf("%s not found", strings.Join(path, "."))
+	case len(members) == 1:
+		return splice(data, object.ValueStart, end, "{}"), nil
+	case i < len(members)-1:
+		return splice(data, members[i].KeyStart, members[i+1].KeyStart, ""), nil
+	default:
+		return splice(data, members[i-1].ValueEnd, members[i].ValueEnd, ""), nil
+	}
+}
+
+// splice returns data with the bytes from start to end replaced by s.
+func splice(data []byte, start int, end int, s string) []byte {
+	edited := make([]byte, 0, len(data)-(end-start)+len(s))
+	edited = append(edited, data[:start]...)
+	edited = append(edited, s...)
+
+	return append(edited, data[end:]...)
+}
+
+// newline returns the line ending data uses.
+func newline(data []byte) string {
+	if bytes.Contains(data, []byte("\r\n")) {
+		return "\r\n"
+	}
+
+	return "\n"
+}
+
+// lineIndent returns the whitespace starting the line that holds offset pos.
+func lineIndent(data []byte, pos int) string {
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	end := start
+	for end < pos && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+
+	return string(data[start:end])
+}
+
+// indentUnit returns the indentation of the first member of the top-level
+// object, which is what data nests with: empty when the object is written on
+// one line, and two spaces when it has no members.
+func indentUnit(data []byte) string {
+	s := &jsonScanner{data: data}
+	s.skipSpace()
+	members, _, err := objectMembers(data, s.pos)
+	if err != nil || len(members) == 0 {
+		return "  "
+	}
+	if !bytes.Contains(data[s.pos:members[0].KeyStart], []byte("\n")) {
+		return ""
+	}
+
+	return lineIndent(data, members[0].KeyStart)
+}
+
+// encodeJSONString encodes s as npm would, without escaping HTML characters.
+func encodeJSONString(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestSetStringMember(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		value string
+		path  []string
+		want  string
+		// err is whether setStringMember must fail.
+		err bool
+	}{
+		{
+			name:  "nested",
+			data:  "{\n  \"dependencies\": {\n    \"dep\": \"^1.0.0\"\n  }\n}\n",
+			value: "^1.2.0",
+			path:  []string{"dependencies", "dep"},
+			want:  "{\n  \"dependencies\": {\n    \"dep\": \"^1.2.0\"\n  }\n}\n",
+		},
+		{
+			name:  "layout kept",
+			data:  "{\r\n\t\"dependencies\" :{ \"dep\"  :  \"1.0.0\",\"other\":\"2.0.0\" } }",
+			value: "1.1.0",
+			path:  []string{"dependencies", "dep"},
+			want:  "{\r\n\t\"dependencies\" :{ \"dep\"  :  \"1.1.0\",\"other\":\"2.0.0\" } }",
+		},
+		{
+			name:  "html not escaped",
+			data:  `{"dependencies": {"dep": "1.0.0"}}`,
+			value: ">=1.0.0 <2.0.0",
+			path:  []string{"dependencies", "dep"},
+			want:  `{"dependencies": {"dep": ">=1.0.0 <2.0.0"}}`,
+		},
+		{
+			name:  "escaped key",
+			data:  `{"dependencies": {"d\u0065p": "1.0.0"}}`,
+			value: "1.1.0",
+			path:  []string{"dependencies", "dep"},
+			want:  `{"dependencies": {"d\u0065p": "1.1.0"}}`,
+		},
+		{
+			name:  "last duplicate wins",
+			data:  `{"dependencies": {"dep": "1.0.0", "dep": "1.0.1"}}`,
+			value: "1.1.0",
+			path:  []string{"dependencies", "dep"},
+			want:  `{"dependencies": {"dep": "1.0.0", "dep": "1.1.0"}}`,
+		},
+		{
+			name:  "same key elsewhere",
+			data:  `{"devDependencies": {"dep": "1.0.0"}, "dependencies": {"dep": "1.0.0"}}`,
+			value: "1.1.0",
+			path:  []string{"dependencies", "dep"},
+			want:  `{"devDependencies": {"dep": "1.0.0"}, "dependencies": {"dep": "1.1.0"}}`,
+		},
+		{
+			name:  "missing member",
+			data:  `{"dependencies": {}}`,
+			value: "1.1.0",
+			path:  []string{"dependencies", "dep"},
+			err:   true,
+		},
+		{
+			name:  "not an object",
+			data:  `{"dependencies": ["dep"]}`,
+			value: "1.1.0",
+			path:  []string{"dependencies", "dep"},
+			err:   true,
+		},
+		{
+			name:  "truncated",
+			data:  `{"dependencies": {"dep": "1.0.0"`,
+			value: "1.1.0",
+			path:  []string{"dependencies", "dep"},
+			err:   true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := setStringMember([]byte(test.data), test.value, test.path...)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: setStringMember succeeded with %s", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		key  string
+		want string
+	}{
+		{
+			name: "sorted",
+			data: "{\n  \"dependencies\": {\n    \"a\": \"1.0.0\",\n    \"c\": \"1.0.0\"\n  }\n}\n",
+			key:  "b",
+			want: "{\n  \"dependencies\": {\n    \"a\": \"1.0.0\",\n    \"b\": \"2.0.0\",\n    \"c\": \"1.0.0\"\n  }\n}\n",
+		},
+		{
+			name: "sorted first",
+			data: "{\n  \"dependencies\": {\n    \"b\": \"1.0.0\",\n    \"c\": \"1.0.0\"\n  }\n}\n",
+			key:  "a",
+			want: "{\n  \"dependencies\": {\n    \"a\": \"2.0.0\",\n    \"b\": \"1.0.0\",\n    \"c\": \"1.0.0\"\n  }\n}\n",
+		},
+		{
+			name: "unsorted appends",
+			data: "{\n  \"dependencies\": {\n    \"c\": \"1.0.0\",\n    \"a\": \"1.0.0\"\n  }\n}\n",
+			key:  "b",
+			want: "{\n  \"dependencies\": {\n    \"c\": \"1.0.0\",\n    \"a\": \"1.0.0\",\n    \"b\": \"2.0.0\"\n  }\n}\n",
+		},
+		{
+			name: "empty object",
+			data: "{\n\t\"dependencies\": {}\n}\n",
+			key:  "a",
+			want: "{\n\t\"dependencies\": {\n\t\t\"a\": \"2.0.0\"\n\t}\n}\n",
+		},
+		{
+			name: "one line",
+			data: `{"dependencies": {"a": "1.0.0"}}`,
+			key:  "b",
+			want: `{"dependencies": {"a": "1.0.0", "b": "2.0.0"}}`,
+		},
+		{
+			name: "one line empty",
+			data: `{"dependencies": {}}`,
+			key:  "b",
+			want: `{"dependencies": {"b": "2.0.0"}}`,
+		},
+		{
+			name: "crlf",
+			data: "{\r\n  \"dependencies\": {}\r\n}\r\n",
+			key:  "a",
+			want: "{\r\n  \"dependencies\": {\r\n    \"a\": \"2.0.0\"\r\n  }\r\n}\r\n",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := addMember([]byte(test.data), test.key, []byte(`"2.0.0"`), "dependencies")
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+
+	if _, err := addMember([]byte(`{}`), "a", []byte(`"2.0.0"`), "dependencies"); err == nil {
+		t.Error("addMember added to a missing object")
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	data := "{\n  \"dependencies\": {\n    \"a\": \"1.0.0\",\n    \"b\": \"1.0.0\",\n    \"c\": \"1.0.0\"\n  }\n}\n"
+	tests := []struct {
+		name string
+		data string
+		key  string
+		want string
+	}{
+		{"first", data, "a", "{\n  \"dependencies\": {\n    \"b\": \"1.0.0\",\n    \"c\": \"1.0.0\"\n  }\n}\n"},
+		{"middle", data, "b", "{\n  \"dependencies\": {\n    \"a\": \"1.0.0\",\n    \"c\": \"1.0.0\"\n  }\n}\n"},
+		{"last", data, "c", "{\n  \"dependencies\": {\n    \"a\": \"1.0.0\",\n    \"b\": \"1.0.0\"\n  }\n}\n"},
+		{"only", "{\n  \"dependencies\": {\n    \"a\": \"1.0.0\"\n  }\n}\n", "a", "{\n  \"dependencies\": {}\n}\n"},
+		{"one line", `{"dependencies": {"a": "1.0.0", "b": "1.0.0"}}`, "b", `{"dependencies": {"a": "1.0.0"}}`},
+	}
+
+	for _, test := range tests {
+		got, err := removeMember([]byte(test.data), "dependencies", test.key)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+
+	if _, err := removeMember([]byte(data), "dependencies", "d"); err == nil {
+		t.Error("removeMember removed a missing member")
+	}
+	if _, err := removeMember([]byte(data)); err == nil {
+		t.Error("removeMember removed the top-level object")
+	}
+}
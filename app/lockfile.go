@@ -0,0 +1,201 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Package managers a repo can use.
+const (
+	PackageManagerNpm       = "npm"
+	PackageManagerYarn      = "yarn"
+	PackageManagerYarnBerry = "yarn-berry"
+	PackageManagerPnpm      = "pnpm"
+)
+
+// LockfileConfig is the lockfile section of the config file.
+type LockfileConfig struct {
+	// Skip leaves lockfiles alone after update.
+	Skip bool `json:"skip"`
+	// Registry is the npm registry the package managers resolve against,
+	// such as a local mirror. Empty means their own configuration.
+	Registry string `json:"registry"`
+}
+
+// PackageManager is how a repo installs its dependencies.
+type PackageManager struct {
+	Name string
+	// Lockfile is the name of its lockfile in the repo.
+	Lockfile string
+}
+
+// lockfiles maps lockfile names to the package manager writing them, in the
+// order they are looked for.
+var lockfiles = []PackageManager{
+	{PackageManagerPnpm, "pnpm-lock.yaml"},
+	{PackageManagerYarn, "yarn.lock"},
+	{PackageManagerNpm, "package-lock.json"},
+	{PackageManagerNpm, "npm-shrinkwrap.json"},
+}
+
+// ConfigFiles are the files besides package.json and the lockfile that
+// package managers read when resolving dependencies.
+var ConfigFiles = []string{".npmrc", ".yarnrc", ".yarnrc.yml", ".pnpmfile.cjs"}
+
+// DetectPackageManager returns the package manager of the repo checked out
+// in dir, judged by its lockfile, and false if it has none. Yarn 2 and later
+// are told apart from yarn 1 by .yarnrc.yml or the packageManager field of
+// package.json.
+func DetectPackageManager(dir string) (PackageManager, bool) {
+	return detectPackageManager(func(name string) bool {
+		return IsValidFile(filepath.Join(dir, name))
+	}, func() []byte {
+		data, _ := os.ReadFile(filepath.Join(dir, "package.json"))
+		return data
+	})
+}
+
+// detectPackageManager does DetectPackageManager for a repo whose root
+// directory has the files for which exists is true, reading package.json
+// with manifest only when needed.
+func detectPackageManager(exists func(name string) bool, manifest func() []byte) (PackageManager, bool) {
+	for _, pm := range lockfiles {
+		if !exists(pm.Lockfile) {
+			continue
+		}
+		if pm.Name == PackageManagerYarn && (exists(".yarnrc.yml") || isYarnBerry(manifest())) {
+			pm.Name = PackageManagerYarnBerry
+		}
+		return pm, true
+	}
+
+	return PackageManager{}, false
+}
+
+func isYarnBerry(data []byte) bool {
+	var manifest struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if json.Unmarshal(data, &manifest) != nil {
+		return false
+	}
+
+	return strings.HasPrefix(manifest.PackageManager, "yarn@") && !strings.HasPrefix(manifest.PackageManager, "yarn@1.")
+}
+
+// command returns the command updating the lockfile to package.json without
+// installing anything, where the package manager allows it. Yarn 1 has no
+// lockfile-only mode, so it installs, but without running scripts, and
+// RegenerateLockfile runs it in a temporary copy of the repo.
+func (pm PackageManager) command() []string {
+	switch pm.Name {
+	case PackageManagerPnpm:
+		return []string{"pnpm", "install", "--lockfile-only", "--ignore-scripts"}
+	case PackageManagerYarnBerry:
+		return []string{"yarn", "install", "--mode=update-lockfile"}
+	case PackageManagerYarn:
+		return []string{"yarn", "install", "--ignore-scripts", "--non-interactive"}
+	default:
+		return []string{"npm", "install", "--package-lock-only", "--ignore-scripts", "--no-audit", "--no-fund"}
+	}
+}
+
+// RegenerateLockfile brings the lockfile of the repo checked out in dir up
+// to date with its package.json using pm. When registry is set, packages are
+// resolved against it. Failures include the tail of the package manager's
+// output. Yarn 1 would install node_modules into the checkout, so it is run
+// on a temporary copy of the files it reads and only the lockfile is written
+// back.
+func RegenerateLockfile(ctx context.Context, dir string, pm PackageManager, registry string) error {
+	if pm.Name != PackageManagerYarn {
+		return runPackageManager(ctx, dir, pm, registry)
+	}
+
+	lockPath := filepath.Join(dir, pm.Lockfile)
+	info, err := os.Stat(lockPath)
+	if err != nil {
+		return err
+	}
+	files := make(map[string][]byte)
+	for _, name := range append([]string{"package.json", pm.Lockfile}, ConfigFiles...) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) && name != "package.json" {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		files[name] = data
+	}
+	lockfile, err := RegenerateLockfileFiles(ctx, files, pm, registry)
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(lockPath, lockfile, info.Mode().Perm())
+}
+
+// runPackageManager runs the command of pm in dir, as RegenerateLockfile
+// describes.
+func runPackageManager(ctx context.Context, dir string, pm PackageManager, registry string) error {
+	args := pm.command()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	if registry != "" {
+		// npm, pnpm and yarn 1 read npm_config_*, yarn 2 and later its own
+		cmd.Env = append(cmd.Env, "npm_config_registry="+registry, "YARN_NPM_REGISTRY_SERVER="+registry)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", strings.Join(args, " "), err, outputTail(output.String()))
+	}
+
+	return nil
+}
+
+// RegenerateLockfileFiles works out the lockfile pm writes for a repo
+// without a checkout, in a temporary directory holding files, which map
+// names to contents: package.json, the current lockfile and any of
+// ConfigFiles. It returns the new lockfile.
+func RegenerateLockfileFiles(ctx context.Context, files map[string][]byte, pm PackageManager, registry string) ([]byte, error) {
+	tmp, err := os.MkdirTemp("", "pacman-lockfile")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, name := range sortedKeys(files) {
+		err = os.WriteFile(filepath.Join(tmp, name), files[name], 0666)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = runPackageManager(ctx, tmp, pm, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(filepath.Join(tmp, pm.Lockfile))
+}
+
+// outputTail returns the last lines of a command's output, which is where
+// package managers print their errors.
+func outputTail(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+
+	return strings.Join(lines, "\n")
+}
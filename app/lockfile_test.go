@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegenerateLockfileYarn1(t *testing.T) {
+	// a fake yarn that installs and rewrites the lockfile, as yarn 1 does
+	bin := t.TempDir()
+	script := "#!/bin/sh\nmkdir node_modules && echo '# regenerated' > yarn.lock\n"
+	err := os.WriteFile(filepath.Join(bin, "yarn"), []byte(script), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{"package.json": "{}\n", "yarn.lock": "# old\n", ".yarnrc": "offline true\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pm, _ := DetectPackageManager(dir)
+	if pm.Name != PackageManagerYarn {
+		t.Fatalf("detected %s, want yarn", pm.Name)
+	}
+	err = RegenerateLockfile(context.Background(), dir, pm, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockfile, err := os.ReadFile(filepath.Join(dir, "yarn.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(lockfile) != "# regenerated\n" {
+		t.Errorf("yarn.lock is %q, want the regenerated one", lockfile)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "node_modules")); !os.IsNotExist(err) {
+		t.Errorf("yarn installed node_modules into the checkout: %v", err)
+	}
+}
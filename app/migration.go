@@ -0,0 +1,183 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Migration is a group of repos that must move a package from one version to
+// another.
+type Migration struct {
+	Package string   `json:"package"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Repos   []string `json:"repos"`
+}
+
+// buildMigrations compares the versions each repo uses before and after
+// unification. Comparing the end states, rather than replaying merges, means
+// a repo moved along a chain of merges is reported once, with its final
+// version. A repo using several versions of a package, such as one in
+// dependencies and another in devDependencies, gets a migration for each
+// version it moves off, so that every From and To is a single version.
+func buildMigrations(before *Inventory, after *Inventory) []Migration {
+	var migrations []Migration
+	for _, name := range sortedKeys(before.Packages) {
+		from := repoVersionSets(before.Packages[name])
+		to := repoVersionSets(after.Packages[name])
+
+		moves := make(map[[2]string][]string)
+		for _, repo := range sortedKeys(from) {
+			for _, version := range sortedKeys(from[repo]) {
+				if to[repo][version] {
+					continue
+				}
+				target := migrationTarget(version, from[repo], to[repo])
+				if target == "" {
+					continue
+				}
+				key := [2]string{version, target}
+				moves[key] = append(moves[key], repo)
+			}
+		}
+		for key, repos := range moves {
+			migrations = append(migrations, Migration{Package: name, From: key[0], To: key[1], Repos: repos})
+		}
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		if migrations[i].Package != migrations[j].Package {
+			return migrations[i].Package < migrations[j].Package
+		}
+		if migrations[i].From != migrations[j].From {
+			return migrations[i].From < migrations[j].From
+		}
+		return migrations[i].To < migrations[j].To
+	})
+
+	return migrations
+}
+
+// repoVersionSets maps each repo using pkg to the set of versions it uses.
+func repoVersionSets(pkg Package) map[string]map[string]bool {
+	versions := make(map[string]map[string]bool)
+	for version, repos := range pkg.Versions {
+		for _, repo := range repos {
+			if versions[repo] == nil {
+				versions[repo] = make(map[string]bool)
+			}
+			versions[repo][version] = true
+		}
+	}
+
+	return versions
+}
+
+// migrationTarget returns the version a repo that used the versions before
+// and uses the versions after moved version to: the one version it took up,
+// or the one it is left with. When there are several, as when prerelease
+// channels are unified separately, it is the lowest one not below version,
+// or else the highest. It is empty when the repo no longer uses the package.
+func migrationTarget(version string, before map[string]bool, after map[string]bool) string {
+	var candidates []string
+	for v := range after {
+		if !before[v] {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = sortedKeys(after)
+	}
+	switch len(candidates) {
+	case 0:
+		return ""
+	case 1:
+		return candidates[0]
+	}
+
+	parsed, err := semver.NewVersion(version)
+	var versions []*semver.Version
+	for _, c := range candidates {
+		if v, err := semver.NewVersion(c); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	if err != nil || len(versions) == 0 {
+		sort.Strings(candidates)
+		return candidates[len(candidates)-1]
+	}
+	sort.Sort(semver.Collection(versions))
+	for _, v := range versions {
+		if !v.LessThan(parsed) {
+			return v.Original()
+		}
+	}
+
+	return versions[len(versions)-1].Original()
+}
+
+// WriteMigrationJSON writes the migrations of result, what was excluded from
+// them and the prereleases in use as indented JSON.
+func WriteMigrationJSON(w io.Writer, result *UnifyResult) error {
+	report := struct {
+		Migrations  []Migration       `json:"migrations"`
+		Excluded    []Exclusion       `json:"excluded"`
+		Prereleases []PrereleaseUsage `json:"prereleases"`
+	}{[]Migration{}, []Exclusion{}, []PrereleaseUsage{}}
+	report.Migrations = append(report.Migrations, result.Migrations...)
+	report.Excluded = append(report.Excluded, result.Excluded...)
+	report.Prereleases = append(report.Prereleases, result.Prereleases...)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// WriteMigrationText writes the migrations of result in a human readable
+// form, grouped by package, followed by what was excluded from them and the
+// prereleases in use.
+func WriteMigrationText(w io.Writer, result *UnifyResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	migrations := result.Migrations
+	if len(migrations) == 0 {
+		fmt.Fprintln(tw, "No repos need to change")
+	} else {
+		fmt.Fprintln(tw, "Migration report:")
+	}
+	for i, migration := range migrations {
+		if i == 0 || migration.Package != migrations[i-1].Package {
+			fmt.Fprintf(tw, "\n%s\n", migration.Package)
+		}
+		fmt.Fprintf(tw, "  %s -> %s\t%s\n", migration.From, migration.To, strings.Join(migration.Repos, ", "))
+	}
+
+	if len(result.Excluded) > 0 {
+		fmt.Fprintln(tw, "\nLeft alone:")
+		for _, exclusion := range result.Excluded {
+			if exclusion.Repo == "" {
+				fmt.Fprintf(tw, "  %s\t\t%s\n", exclusion.Package, exclusion.Reason)
+			} else {
+				fmt.Fprintf(tw, "  %s@%s\t%s\t%s\n", exclusion.Package, exclusion.Version, exclusion.Repo, exclusion.Reason)
+			}
+		}
+	}
+
+	if len(result.Prereleases) > 0 {
+		fmt.Fprintln(tw, "\nPrereleases in use:")
+		for _, usage := range result.Prereleases {
+			fmt.Fprintf(tw, "  %s@%s\t%s\t%s\n", usage.Package, usage.Version, usage.Channel, strings.Join(usage.Repos, ", "))
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Npmrc holds the settings of .npmrc files, by key.
+type Npmrc map[string]string
+
+// DefaultRegistry is the registry npm uses when .npmrc sets none.
+const DefaultRegistry = "https://registry.npmjs.org/"
+
+// envReference matches ${NAME} references to environment variables.
+var envReference = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// ParseNpmrc parses the ini-style contents of an .npmrc file. Comments and
+// lines that are not key=value pairs are skipped. References to environment
+// variables, such as ${NPM_TOKEN}, are expanded as npm does; unset ones are
+// left as they are.
+func ParseNpmrc(data []byte) Npmrc {
+	npmrc := make(Npmrc)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		npmrc[strings.TrimSpace(key)] = envReference.ReplaceAllStringFunc(value, func(ref string) string {
+			if env, exists := os.LookupEnv(ref[2 : len(ref)-1]); exists {
+				return env
+			}
+			return ref
+		})
+	}
+
+	return npmrc
+}
+
+// ReadNpmrc reads and merges the .npmrc files at paths, skipping those that
+// do not exist. Settings of later files win, so paths go from the most
+// general, such as ~/.npmrc, to the most specific.
+func ReadNpmrc(paths ...string) (Npmrc, error) {
+	npmrc := make(Npmrc)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range ParseNpmrc(data) {
+			npmrc[key] = value
+		}
+	}
+
+	return npmrc, nil
+}
+
+// merge returns the settings of n overridden by those of other.
+func (n Npmrc) merge(other Npmrc) Npmrc {
+	merged := make(Npmrc, len(n)+len(other))
+	for key, value := range n {
+		merged[key] = value
+	}
+	for key, value := range other {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// SavePrefix returns the prefix npm install saves versions with: empty when
+// save-exact is set, otherwise save-prefix, which defaults to a caret.
+func (n Npmrc) SavePrefix() string {
+	if n["save-exact"] == "true" {
+		return ""
+	}
+	if prefix, exists := n["save-prefix"]; exists {
+		return prefix
+	}
+
+	return RangeCaret
+}
+
+// RegistryFor returns the registry package pkg is fetched from: the
+// @scope:registry of its scope, if set, otherwise registry, otherwise
+// DefaultRegistry. The URL always ends with a slash.
+func (n Npmrc) RegistryFor(pkg string) string {
+	registry := n["registry"]
+	if strings.HasPrefix(pkg, "@") {
+		scope, _, _ := strings.Cut(pkg, "/")
+		if scoped, exists := n[scope+":registry"]; exists {
+			registry = scoped
+		}
+	}
+	if registry == "" {
+		registry = DefaultRegistry
+	}
+	if !strings.HasSuffix(registry, "/") {
+		registry += "/"
+	}
+
+	return registry
+}
+
+// Authorization returns the Authorization header to send to registry, and
+// false if there are no credentials for it. Credentials scoped to the
+// registry, such as //registry.example.com/:_authToken, are always sent;
+// unscoped ones only to the default registry and only with always-auth, as
+// older versions of npm did.
+func (n Npmrc) Authorization(registry string) (string, bool) {
+	if prefix := nerfDart(registry); prefix != "" {
+		if auth, ok := n.credentials(prefix); ok {
+			return auth, true
+		}
+	}
+	if n["always-auth"] == "true" && strings.TrimSuffix(registry, "/") == strings.TrimSuffix(n.RegistryFor(""), "/") {
+		return n.credentials("")
+	}
+
+	return "", false
+}
+
+// credentials returns the Authorization header for the settings with prefix,
+// either a registry's nerf dart or empty for the unscoped ones.
+func (n Npmrc) credentials(prefix string) (string, bool) {
+	if token := n[prefix+"_authToken"]; token != "" {
+		return "Bearer " + token, true
+	}
+	if auth := n[prefix+"_auth"]; auth != "" {
+		return "Basic " + auth, true
+	}
+	if user, password := n[prefix+"username"], n[prefix+"_password"]; user != "" && password != "" {
+		// _password is base64 encoded in .npmrc
+		decoded, err := base64.StdEncoding.DecodeString(password)
+		if err != nil {
+			return "", false
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+string(decoded))), true
+	}
+
+	return "", false
+}
+
+// nerfDart returns the prefix of the .npmrc settings scoped to registry: its
+// URL without the protocol, such as //registry.example.com/npm/:, or empty
+// if registry is not a URL.
+func nerfDart(registry string) string {
+	u, err := url.Parse(registry)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	path := u.Path
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+
+	return "//" + u.Host + path + ":"
+}
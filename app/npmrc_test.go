@@ -0,0 +1,159 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNpmrc(t *testing.T) {
+	t.Setenv("NPM_TOKEN", "secret")
+	data := `
+# comment
+; also a comment
+registry = https://npm.example.com/
+save-prefix="~"
+@acme:registry='https://npm.acme.example/'
+//npm.example.com/:_authToken=${NPM_TOKEN}
+//other.example.com/:_authToken=${UNSET_NPM_TOKEN}
+not a setting
+url=https://example.com/?a=b
+`
+	want := Npmrc{
+		"registry":                        "https://npm.example.com/",
+		"save-prefix":                     "~",
+		"@acme:registry":                  "https://npm.acme.example/",
+		"//npm.example.com/:_authToken":   "secret",
+		"//other.example.com/:_authToken": "${UNSET_NPM_TOKEN}",
+		"url":                             "https://example.com/?a=b",
+	}
+	if got := ParseNpmrc([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseNpmrc() = %v, want %v", got, want)
+	}
+}
+
+func TestSavePrefix(t *testing.T) {
+	tests := []struct {
+		npmrc Npmrc
+		want  string
+	}{
+		{Npmrc{}, RangeCaret},
+		{Npmrc{"save-prefix": "~"}, RangeTilde},
+		{Npmrc{"save-prefix": ""}, ""},
+		{Npmrc{"save-exact": "true", "save-prefix": "~"}, ""},
+		{Npmrc{"save-exact": "false"}, RangeCaret},
+	}
+	for _, test := range tests {
+		if got := test.npmrc.SavePrefix(); got != test.want {
+			t.Errorf("%v: SavePrefix() = %q, want %q", test.npmrc, got, test.want)
+		}
+	}
+}
+
+func TestRegistryFor(t *testing.T) {
+	npmrc := Npmrc{
+		"registry":       "https://npm.example.com",
+		"@acme:registry": "https://npm.acme.example/npm/",
+	}
+	tests := []struct {
+		npmrc Npmrc
+		pkg   string
+		want  string
+	}{
+		{Npmrc{}, "left-pad", DefaultRegistry},
+		{Npmrc{}, "@acme/widgets", DefaultRegistry},
+		{npmrc, "left-pad", "https://npm.example.com/"},
+		{npmrc, "@acme/widgets", "https://npm.acme.example/npm/"},
+		{npmrc, "@other/widgets", "https://npm.example.com/"},
+		{Npmrc{"@acme:registry": "https://npm.acme.example"}, "left-pad", DefaultRegistry},
+	}
+	for _, test := range tests {
+		if got := test.npmrc.RegistryFor(test.pkg); got != test.want {
+			t.Errorf("%v: RegistryFor(%q) = %q, want %q", test.npmrc, test.pkg, got, test.want)
+		}
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	tests := []struct {
+		name     string
+		npmrc    Npmrc
+		registry string
+		want     string
+	}{
+		{
+			name:     "scoped token",
+			npmrc:    Npmrc{"//npm.example.com/:_authToken": "token"},
+			registry: "https://npm.example.com/",
+			want:     "Bearer token",
+		},
+		{
+			name:     "scoped token with a path",
+			npmrc:    Npmrc{"//npm.example.com/npm/:_authToken": "token"},
+			registry: "https://npm.example.com/npm",
+			want:     "Bearer token",
+		},
+		{
+			name:     "scoped auth",
+			npmrc:    Npmrc{"//npm.example.com/:_auth": "dXNlcjpwYXNz"},
+			registry: "https://npm.example.com/",
+			want:     "Basic dXNlcjpwYXNz",
+		},
+		{
+			name: "username and password",
+			// the password is pass, base64 encoded
+			npmrc:    Npmrc{"//npm.example.com/:username": "user", "//npm.example.com/:_password": "cGFzcw=="},
+			registry: "https://npm.example.com/",
+			want:     "Basic dXNlcjpwYXNz",
+		},
+		{
+			name:     "password not base64",
+			npmrc:    Npmrc{"//npm.example.com/:username": "user", "//npm.example.com/:_password": "pass!"},
+			registry: "https://npm.example.com/",
+		},
+		{
+			name:     "token first",
+			npmrc:    Npmrc{"//npm.example.com/:_authToken": "token", "//npm.example.com/:_auth": "dXNlcjpwYXNz"},
+			registry: "https://npm.example.com/",
+			want:     "Bearer token",
+		},
+		{
+			name:     "other registry",
+			npmrc:    Npmrc{"//npm.example.com/:_authToken": "token"},
+			registry: "https://npm.other.example/",
+		},
+		{
+			name:     "unscoped without always-auth",
+			npmrc:    Npmrc{"_authToken": "token"},
+			registry: DefaultRegistry,
+		},
+		{
+			name:     "unscoped with always-auth",
+			npmrc:    Npmrc{"_authToken": "token", "always-auth": "true"},
+			registry: DefaultRegistry,
+			want:     "Bearer token",
+		},
+		{
+			name:     "unscoped to the configured registry",
+			npmrc:    Npmrc{"_authToken": "token", "always-auth": "true", "registry": "https://npm.example.com"},
+			registry: "https://npm.example.com/",
+			want:     "Bearer token",
+		},
+		{
+			name:     "unscoped not to other registries",
+			npmrc:    Npmrc{"_authToken": "token", "always-auth": "true"},
+			registry: "https://npm.other.example/",
+		},
+		{
+			name:     "not a url",
+			npmrc:    Npmrc{"//npm.example.com/:_authToken": "token"},
+			registry: "npm.example.com",
+		},
+	}
+
+	for _, test := range tests {
+		got, ok := test.npmrc.Authorization(test.registry)
+		if ok != (test.want != "") || got != test.want {
+			t.Errorf("%s: Authorization(%q) = %q, %t, want %q", test.name, test.registry, got, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// How far a repo is behind the latest version of a package.
+const (
+	LagMajor = "major"
+	LagMinor = "minor"
+	LagPatch = "patch"
+	// LagUnknown marks a repo whose version could not be worked out, such
+	// as one depending on a git URL, a file or a range nothing published
+	// satisfies. It is always reported.
+	LagUnknown = "unknown"
+)
+
+var lagSeverity = map[string]int{
+	"":       0,
+	LagPatch: 1,
+	LagMinor: 2,
+	LagMajor: 3,
+}
+
+// PackumentSource provides the packuments of packages. RegistryClient is
+// one.
+type PackumentSource interface {
+	Packument(ctx context.Context, name string) (*Packument, error)
+}
+
+// RegistryQuery selects the packages of an inventory, and the repos using
+// them, that are checked against a registry.
+type RegistryQuery struct {
+	// Packages and Repos are path.Match patterns limiting the query to
+	// matching packages and repos. Empty means all of them.
+	Packages []string
+	Repos    []string
+	// Exclusions leaves ignored packages and repos out.
+	Exclusions Exclusions
+	// Jobs is how many packuments are fetched at once.
+	Jobs int
+}
+
+func (q RegistryQuery) validate() error {
+	for _, pattern := range append(append([]string{}, q.Packages...), q.Repos...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return q.Exclusions.validate()
+}
+
+// versions maps each selected package to the versions the selected repos
+// using it record, and the repos recording each.
+func (q RegistryQuery) versions(inv *Inventory) map[string]map[string][]string {
+	versions := make(map[string]map[string][]string)
+	for _, name := range sortedKeys(inv.Packages) {
+		if !matchesAny(q.Packages, name) {
+			continue
+		}
+		if _, ignored := q.Exclusions.ignoredPackage(name); ignored {
+			continue
+		}
+		for version, repos := range inv.Packages[name].Versions {
+			for _, repo := range repos {
+				if _, ignored := q.Exclusions.Ignore.Repos[repo]; ignored || !matchesAny(q.Repos, repo) {
+					continue
+				}
+				if versions[name] == nil {
+					versions[name] = make(map[string][]string)
+				}
+				versions[name][version] = append(versions[name][version], repo)
+			}
+		}
+	}
+
+	return versions
+}
+
+// uses maps each selected package to the selected repos using it and the
+// version each uses. A repo using several versions gets the lowest.
+func (q RegistryQuery) uses(inv *Inventory) map[string]map[string]string {
+	uses := make(map[string]map[string]string)
+	for name, versions := range q.versions(inv) {
+		uses[name] = make(map[string]string)
+		for _, version := range sortedVersions(versions) {
+			for _, repo := range versions[version] {
+				if _, exists := uses[name][repo]; !exists {
+					uses[name][repo] = version
+				}
+			}
+		}
+	}
+
+	return uses
+}
+
+// OutdatedOptions selects what Outdated reports.
+type OutdatedOptions struct {
+	RegistryQuery
+	// Severity is the least lag reported: LagPatch, the default, LagMinor
+	// or LagMajor.
+	Severity string
+	// All reports repos that are up to date too.
+	All bool
+}
+
+func (opts OutdatedOptions) validate() error {
+	if _, exists := lagSeverity[opts.Severity]; !exists {
+		return fmt.Errorf("unknown severity %q", opts.Severity)
+	}
+
+	return opts.RegistryQuery.validate()
+}
+
+// OutdatedReport is a package × repo matrix of how far behind the latest
+// version of each package the repos are.
+type OutdatedReport struct {
+	// Repos are the columns: every repo with a dependency in the report.
+	Repos    []string          `json:"repos"`
+	Packages []OutdatedPackage `json:"packages"`
+}
+
+// OutdatedPackage is a row of an OutdatedReport.
+type OutdatedPackage struct {
+	Name   string `json:"name"`
+	Latest string `json:"latest,omitempty"`
+	// Repos maps each reported repo to its use of the package.
+	Repos map[string]OutdatedDependency `json:"repos,omitempty"`
+	// Error is why the package could not be checked.
+	Error string `json:"error,omitempty"`
+}
+
+// OutdatedDependency is a repo's use of a package.
+type OutdatedDependency struct {
+	Range string `json:"range,omitempty"`
+	// Current is the version the repo uses: the one its range resolves to
+	// when the inventory does not record a version, or what the inventory
+	// records when Lag is LagUnknown.
+	Current string `json:"current"`
+	// Wanted is the newest version Range allows, as npm outdated has it.
+	Wanted string `json:"wanted,omitempty"`
+	// Lag is LagMajor, LagMinor or LagPatch, LagUnknown when Current could
+	// not be worked out, or empty when the repo is up to date.
+	Lag string `json:"lag,omitempty"`
+	// Deprecated is the registry's deprecation message for Current.
+	Deprecated string `json:"deprecated,omitempty"`
+	Dev        bool   `json:"dev,omitempty"`
+}
+
+// Failed returns the packages that could not be checked.
+func (r *OutdatedReport) Failed() []OutdatedPackage {
+	var failed []OutdatedPackage
+	for _, pkg := range r.Packages {
+		if pkg.Error != "" {
+			failed = append(failed, pkg)
+		}
+	}
+
+	return failed
+}
+
+// Check returns why the outdated command fails, with fail: packages could
+// not be fetched.
+func (r *OutdatedReport) Check(fail bool) error {
+	if failed := r.Failed(); fail && len(failed) > 0 {
+		return fmt.Errorf("could not check %d package(s)", len(failed))
+	}
+
+	return nil
+}
+
+// Outdated compares the version each repo in inv uses of each package with
+// the versions published, as told by source.
+func Outdated(ctx context.Context, inv *Inventory, source PackumentSource, opts OutdatedOptions) (*OutdatedReport, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	uses := opts.uses(inv)
+	packuments, errs := fetchPackuments(ctx, source, sortedKeys(uses), opts.Jobs)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &OutdatedReport{Repos: []string{}, Packages: []OutdatedPackage{}}
+	columns := make(map[string]bool)
+	for _, name := range sortedKeys(uses) {
+		if err := errs[name]; err != nil {
+			report.Packages = append(report.Packages, OutdatedPackage{Name: name, Error: err.Error()})
+			continue
+		}
+		packument := packuments[name]
+		latest := latestVersion(packument)
+		row := OutdatedPackage{Name: name, Repos: make(map[string]OutdatedDependency)}
+		if latest != nil {
+			row.Latest = latest.Original()
+		}
+
+		for repo, recorded := range uses[name] {
+			spec, _ := inv.DeclaredSpec(repo, name)
+			_, dev := inv.Repos[repo].DevDependencies[name]
+			dep := OutdatedDependency{Range: spec, Current: recorded, Dev: dev}
+			dep.Wanted = wantedVersion(packument, spec, latest)
+			if current, resolved := currentVersion(packument, recorded, spec, latest); resolved {
+				dep.Current = current
+				dep.Deprecated = packument.Versions[current].Deprecated
+				dep.Lag = lag(current, latest)
+			} else {
+				dep.Lag = LagUnknown
+			}
+			if !opts.All && dep.Lag != LagUnknown && (dep.Lag == "" || lagSeverity[dep.Lag] < lagSeverity[opts.Severity]) {
+				continue
+			}
+			row.Repos[repo] = dep
+			columns[repo] = true
+		}
+		if len(row.Repos) > 0 {
+			report.Packages = append(report.Packages, row)
+		}
+	}
+	report.Repos = append(report.Repos, sortedKeys(columns)...)
+
+	return report, nil
+}
+
+// fetchPackuments fetches the packuments of names from source, jobs at a
+// time, and returns them with the errors of those that could not be fetched.
+func fetchPackuments(ctx context.Context, source PackumentSource, names []string, jobs int) (map[string]*Packument, map[string]error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var mu sync.Mutex
+	packuments := make(map[string]*Packument)
+	errs := make(map[string]error)
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				packument, err := source.Packument(ctx, name)
+				mu.Lock()
+				if err != nil {
+					errs[name] = err
+				} else {
+					packuments[name] = packument
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+
+	return packuments, errs
+}
+
+// latestVersion returns the version the latest dist-tag points at or, if it
+// has none, the highest stable version published.
+func latestVersion(packument *Packument) *semver.Version {
+	if v, err := semver.NewVersion(packument.DistTags["latest"]); err == nil {
+		return v
+	}
+
+	var latest *semver.Version
+	for version := range packument.Versions {
+		v, err := semver.NewVersion(version)
+		if err == nil && v.Prerelease() == "" && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+		}
+	}
+
+	return latest
+}
+
+// wantedVersion returns the version npm would install for spec: latest if
+// spec allows it, otherwise the highest version it allows. It is empty when
+// spec is not a semver range or allows no published version.
+func wantedVersion(packument *Packument, spec string, latest *semver.Version) string {
+	c, err := semver.NewConstraint(spec)
+	if err != nil {
+		return ""
+	}
+	if latest != nil && c.Check(latest) {
+		return latest.Original()
+	}
+
+	var wanted *semver.Version
+	for version := range packument.Versions {
+		v, err := semver.NewVersion(version)
+		if err == nil && c.Check(v) && (wanted == nil || v.GreaterThan(wanted)) {
+			wanted = v
+		}
+	}
+	if wanted == nil {
+		return ""
+	}
+
+	return wanted.Original()
+}
+
+// currentVersion returns the version a repo declaring spec uses, given that
+// the inventory records recorded for it: recorded itself when it is a
+// version, otherwise the version spec resolves to in packument, as npm
+// install would pick it for a dist-tag or a range. It is false when neither
+// gives a version.
+func currentVersion(packument *Packument, recorded string, spec string, latest *semver.Version) (string, bool) {
+	if _, err := semver.StrictNewVersion(recorded); err == nil {
+		return recorded, true
+	}
+	if spec == "" {
+		spec = recorded
+	}
+	if v, err := semver.StrictNewVersion(packument.DistTags[strings.TrimSpace(spec)]); err == nil {
+		return v.Original(), true
+	}
+	if wanted := wantedVersion(packument, spec, latest); wanted != "" {
+		return wanted, true
+	}
+
+	return "", false
+}
+
+// lag returns how far current is behind latest.
+func lag(current string, latest *semver.Version) string {
+	v, err := semver.NewVersion(current)
+	if err != nil || latest == nil || !v.LessThan(latest) {
+		return ""
+	}
+
+	switch {
+	case v.Major() != latest.Major():
+		return LagMajor
+	case v.Minor() != latest.Minor():
+		return LagMinor
+	default:
+		return LagPatch
+	}
+}
+
+// WriteOutdatedJSON writes report as indented JSON.
+func WriteOutdatedJSON(w io.Writer, report *OutdatedReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// WriteOutdatedCSV writes report as CSV, one row per package and repo, for
+// spreadsheets. Packages that could not be checked are left out.
+func WriteOutdatedCSV(w io.Writer, report *OutdatedReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"package", "repo", "dev", "range", "current", "wanted", "latest", "lag", "deprecated"})
+	for _, pkg := range report.Packages {
+		for _, repo := range sortedKeys(pkg.Repos) {
+			dep := pkg.Repos[repo]
+			cw.Write([]string{pkg.Name, repo, strconv.FormatBool(dep.Dev), dep.Range, dep.Current, dep.Wanted, pkg.Latest, dep.Lag, dep.Deprecated})
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteOutdatedText writes report as a table with a row per package and a
+// column per repo. A cell holds the version the repo uses and, when it is
+// behind, the version its range allows and how far behind it is, and whether
+// it is deprecated.
+func WriteOutdatedText(w io.Writer, report *OutdatedReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := 0
+	for _, pkg := range report.Packages {
+		if pkg.Error == "" {
+			rows++
+		}
+	}
+	if rows == 0 {
+		fmt.Fprintln(tw, "Everything is up to date")
+	} else {
+		fmt.Fprint(tw, "Package\tLatest")
+		for _, repo := range report.Repos {
+			fmt.Fprintf(tw, "\t%s", repo)
+		}
+		fmt.Fprintln(tw)
+		for _, pkg := range report.Packages {
+			if pkg.Error != "" {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s", pkg.Name, pkg.Latest)
+			for _, repo := range report.Repos {
+				fmt.Fprintf(tw, "\t%s", formatOutdatedCell(pkg.Repos, repo))
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		fmt.Fprintln(tw, "\nCould not check:")
+		for _, pkg := range failed {
+			fmt.Fprintf(tw, "  %s\t%s\n", pkg.Name, pkg.Error)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func formatOutdatedCell(repos map[string]OutdatedDependency, repo string) string {
+	dep, exists := repos[repo]
+	if !exists {
+		return "-"
+	}
+
+	var notes []string
+	if dep.Lag != "" && dep.Wanted != "" && dep.Wanted != dep.Current {
+		notes = append(notes, dep.Wanted)
+	}
+	if dep.Lag != "" {
+		notes = append(notes, dep.Lag)
+	}
+	if dep.Deprecated != "" {
+		notes = append(notes, "deprecated")
+	}
+	if len(notes) == 0 {
+		return dep.Current
+	}
+
+	return dep.Current + " (" + strings.Join(notes, ", ") + ")"
+}
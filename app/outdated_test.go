@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// packuments is a PackumentSource with fixed packuments.
+type packuments map[string]*Packument
+
+func (p packuments) Packument(ctx context.Context, name string) (*Packument, error) {
+	packument, exists := p[name]
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", name, ErrPackageNotFound)
+	}
+
+	return packument, nil
+}
+
+func TestOutdatedCurrent(t *testing.T) {
+	source := packuments{"dep": {
+		DistTags: map[string]string{"latest": "2.0.0", "next": "2.1.0-beta.1"},
+		Versions: map[string]PackumentVersion{
+			"1.0.0":        {},
+			"1.5.0":        {Deprecated: "use 2"},
+			"2.0.0":        {},
+			"2.1.0-beta.1": {},
+		},
+	}}
+
+	tests := map[string]OutdatedDependency{
+		"1.0.0":   {Current: "1.0.0", Wanted: "1.0.0", Lag: LagMajor},
+		"^1.0.0":  {Current: "1.0.0", Wanted: "1.5.0", Lag: LagMajor},
+		"1.x":     {Current: "1.5.0", Wanted: "1.5.0", Lag: LagMajor, Deprecated: "use 2"},
+		"^2.0.0":  {Current: "2.0.0", Wanted: "2.0.0"},
+		"latest":  {Current: "2.0.0"},
+		"next":    {Current: "2.1.0-beta.1"},
+		"*":       {Current: "2.0.0", Wanted: "2.0.0"},
+		"4":       {Current: "4", Lag: LagUnknown},
+		"git+ssh": {Current: "git+ssh://git@github.com/org/dep.git", Lag: LagUnknown},
+	}
+	specs := map[string]string{}
+	for name := range tests {
+		specs[name] = name
+	}
+	specs["git+ssh"] = "git+ssh://git@github.com/org/dep.git"
+	inv := inventoryOf(t, manifestsWith(specs))
+
+	for _, all := range []bool{true, false} {
+		report, err := Outdated(context.Background(), inv, source, OutdatedOptions{All: all})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Packages) != 1 {
+			t.Fatalf("report has packages %+v, want dep", report.Packages)
+		}
+		for repo, want := range tests {
+			got, reported := report.Packages[0].Repos[repo]
+			if behind := want.Lag != ""; !all && reported != behind {
+				t.Errorf("%s: reported %v without --all", repo, reported)
+			}
+			if !reported {
+				continue
+			}
+			want.Range = specs[repo]
+			if got != want {
+				t.Errorf("%s: got %+v, want %+v", repo, got, want)
+			}
+		}
+	}
+}
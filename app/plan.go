@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// PlanSchemaVersion is the version of the plan file format written by this
+// build.
+const PlanSchemaVersion = 1
+
+// Plan is the outcome of unify written down for review instead of being
+// applied. It can be edited, by changing a target or removing a step, and is
+// then applied with ApplyPlan.
+type Plan struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// Inventory is the fingerprint of the inventory the plan was made from.
+	// A plan only applies to that inventory.
+	Inventory string     `json:"inventory"`
+	Steps     []PlanStep `json:"steps"`
+	// Conflicts are the packages unify could not unify, for the reviewer's
+	// information. They are not applied.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	// Excluded are the packages and repos the config told unify to leave
+	// alone, with the reasons why.
+	Excluded []Exclusion `json:"excluded,omitempty"`
+	// Prereleases are the prereleases that stay in use once the plan is
+	// applied.
+	Prereleases []PrereleaseUsage `json:"prereleases,omitempty"`
+}
+
+// PlanStep moves repos from the versions of a package they use to a target
+// version. Only the repos listed in Current are moved.
+type PlanStep struct {
+	Package string `json:"package"`
+	// Current maps each version to the repos that move off it.
+	Current   map[string][]string `json:"current"`
+	Target    string              `json:"target"`
+	Rationale string              `json:"rationale"`
+}
+
+// NewPlan writes down the migrations of result, which Unify made from inv.
+func NewPlan(inv *Inventory, result *UnifyResult) *Plan {
+	// follow chains of merges to the reason for the version each one ends on
+	final := make(map[[2]string]Merge)
+	for _, merge := range result.Merges {
+		final[[2]string{merge.Package, merge.From}] = merge
+	}
+	rationale := func(pkg string, from string) string {
+		merge := final[[2]string{pkg, from}]
+		for {
+			next, exists := final[[2]string{pkg, merge.To}]
+			if !exists {
+				return merge.Reason
+			}
+			merge = next
+		}
+	}
+
+	plan := &Plan{
+		SchemaVersion: PlanSchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Inventory:     inv.Fingerprint(),
+		Steps:         []PlanStep{},
+		Conflicts:     result.Conflicts,
+		Excluded:      result.Excluded,
+		Prereleases:   result.Prereleases,
+	}
+	steps := make(map[[2]string]int)
+	for _, migration := range result.Migrations {
+		key := [2]string{migration.Package, migration.To}
+		i, exists := steps[key]
+		if !exists {
+			i = len(plan.Steps)
+			steps[key] = i
+			plan.Steps = append(plan.Steps, PlanStep{
+				Package:   migration.Package,
+				Current:   make(map[string][]string),
+				Target:    migration.To,
+				Rationale: rationale(migration.Package, migration.From),
+			})
+		}
+		step := &plan.Steps[i]
+		step.Current[migration.From] = migration.Repos
+	}
+
+	return plan
+}
+
+// EncodePlan encodes plan in the plan file format.
+func EncodePlan(plan *Plan) []byte {
+	return marshalManifest(plan)
+}
+
+// DecodePlan decodes a plan file.
+func DecodePlan(data []byte) (*Plan, error) {
+	var plan Plan
+	err := json.Unmarshal(data, &plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	if plan.SchemaVersion != PlanSchemaVersion {
+		return nil, fmt.Errorf("plan has schema version %d, but this pacman only understands %d",
+			plan.SchemaVersion, PlanSchemaVersion)
+	}
+
+	return &plan, nil
+}
+
+// ApplyPlan applies plan to inv. It refuses a plan made from a different
+// inventory, and steps that no longer match it after editing or that move
+// something exclusions leave alone, so that a plan is applied completely or
+// not at all. inv is left untouched.
+func ApplyPlan(inv *Inventory, plan *Plan, exclusions Exclusions) (*UnifyResult, error) {
+	if plan.Inventory != inv.Fingerprint() {
+		return nil, fmt.Errorf("plan is stale: the inventory changed since it was made; run unify --plan again")
+	}
+	err := exclusions.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UnifyResult{Inventory: inv.Clone(), Excluded: plan.Excluded}
+	for _, step := range plan.Steps {
+		pkg, exists := result.Inventory.Packages[step.Package]
+		if !exists {
+			return nil, fmt.Errorf("plan step for %s: no such package", step.Package)
+		}
+		if _, err := semver.NewVersion(step.Target); err != nil {
+			return nil, fmt.Errorf("plan step for %s: target %q is not a valid version", step.Package, step.Target)
+		}
+		if reason, ignored := exclusions.ignoredPackage(step.Package); ignored {
+			return nil, fmt.Errorf("plan step for %s: package is ignored: %s", step.Package, reason)
+		}
+
+		for _, version := range sortedVersions(step.Current) {
+			if version == step.Target {
+				continue
+			}
+			for _, repo := range step.Current[version] {
+				if !contains(pkg.Versions[version], repo) {
+					return nil, fmt.Errorf("plan step for %s: %s does not use %s, or is already moved by another step",
+						step.Package, repo, version)
+				}
+				if reason, held := exclusions.heldRepo(step.Package, repo, version); held {
+					return nil, fmt.Errorf("plan step for %s: %s@%s is held: %s", step.Package, repo, version, reason)
+				}
+				moveRepo(pkg, repo, version, step.Target)
+			}
+			result.Merges = append(result.Merges, Merge{step.Package, version, step.Target, step.Rationale})
+		}
+	}
+	result.Migrations = buildMigrations(inv, result.Inventory)
+	result.Prereleases = prereleasesInUse(result.Inventory)
+
+	return result, nil
+}
+
+// moveRepo moves repo from version from of pkg to version to.
+func moveRepo(pkg Package, repo string, from string, to string) {
+	var rest []string
+	for _, r := range pkg.Versions[from] {
+		if r != repo {
+			rest = append(rest, r)
+		}
+	}
+	if len(rest) == 0 {
+		delete(pkg.Versions, from)
+	} else {
+		pkg.Versions[from] = rest
+	}
+	if !contains(pkg.Versions[to], repo) {
+		pkg.Versions[to] = append(pkg.Versions[to], repo)
+		sort.Strings(pkg.Versions[to])
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planManifests has repo a use dep 1.0.0 in dependencies and 1.2.0 in
+// devDependencies, b use 1.2.0 and c use 1.1.0.
+var planManifests = map[string][]byte{
+	"a": []byte(`{"dependencies": {"dep": "1.0.0"}, "devDependencies": {"dep": "1.2.0"}}`),
+	"b": []byte(`{"dependencies": {"dep": "1.2.0"}}`),
+	"c": []byte(`{"dependencies": {"dep": "1.1.0"}}`),
+}
+
+func TestMigrationsPerVersion(t *testing.T) {
+	inv := inventoryOf(t, planManifests)
+	result, err := Unify(context.Background(), inv, UnifyOptions{Strategy: StrategyHighest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Package: "dep", From: "1.0.0", To: "1.2.0", Repos: []string{"a"}},
+		{Package: "dep", From: "1.1.0", To: "1.2.0", Repos: []string{"c"}},
+	}
+	if !reflect.DeepEqual(result.Migrations, want) {
+		t.Errorf("migrations %+v, want %+v", result.Migrations, want)
+	}
+}
+
+func TestApplyPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		// edit changes the plan, and exclusions are applied with it.
+		edit       func(plan *Plan)
+		exclusions Exclusions
+		// err is part of the error ApplyPlan must return, empty if it must
+		// apply the plan as unify would have.
+		err string
+		// migrations, if set, are what an edited plan must migrate instead
+		// of what unify did.
+		migrations []Migration
+	}{
+		{name: "unchanged"},
+		{
+			name:       "repo removed",
+			edit:       func(plan *Plan) { delete(plan.Steps[0].Current, "1.1.0") },
+			migrations: []Migration{{Package: "dep", From: "1.0.0", To: "1.2.0", Repos: []string{"a"}}},
+		},
+		{
+			name: "stale",
+			edit: func(plan *Plan) { plan.Inventory = "0000" },
+			err:  "plan is stale",
+		},
+		{
+			name: "unknown package",
+			edit: func(plan *Plan) { plan.Steps[0].Package = "other" },
+			err:  "no such package",
+		},
+		{
+			name: "invalid target",
+			edit: func(plan *Plan) { plan.Steps[0].Target = "latest" },
+			err:  "not a valid version",
+		},
+		{
+			name: "repo not on version",
+			edit: func(plan *Plan) { plan.Steps[0].Current["1.1.0"] = []string{"b"} },
+			err:  "b does not use 1.1.0",
+		},
+		{
+			name:       "held repo",
+			exclusions: Exclusions{Holds: []Hold{{Package: "dep", Repos: []string{"c"}, Reason: "pinned"}}},
+			err:        "c@1.1.0 is held: pinned",
+		},
+		{
+			name:       "ignored package",
+			exclusions: Exclusions{Ignore: IgnoreConfig{Packages: map[string]string{"d*": "vendored"}}},
+			err:        "package is ignored: vendored",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inv := inventoryOf(t, planManifests)
+			unified, err := Unify(context.Background(), inv, UnifyOptions{Strategy: StrategyHighest})
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := DecodePlan(EncodePlan(NewPlan(inv, unified)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Steps) != 1 {
+				t.Fatalf("plan has steps %+v, want one", plan.Steps)
+			}
+			if test.edit != nil {
+				test.edit(plan)
+			}
+
+			result, err := ApplyPlan(inv, plan, test.exclusions)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ApplyPlan returned %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.migrations != nil {
+				if !reflect.DeepEqual(result.Migrations, test.migrations) {
+					t.Errorf("applied plan migrates %+v, want %+v", result.Migrations, test.migrations)
+				}
+				return
+			}
+			if got, want := result.Inventory.Fingerprint(), unified.Inventory.Fingerprint(); got != want {
+				t.Errorf("applied plan gives %v, unify gave %v", result.Inventory.Packages, unified.Inventory.Packages)
+			}
+			if !reflect.DeepEqual(result.Migrations, unified.Migrations) {
+				t.Errorf("applied plan migrates %+v, unify migrated %+v", result.Migrations, unified.Migrations)
+			}
+			if inv.Fingerprint() != plan.Inventory {
+				t.Error("ApplyPlan changed the inventory it was given")
+			}
+		})
+	}
+}
+
+func TestDecodePlanSchemaVersion(t *testing.T) {
+	_, err := DecodePlan([]byte(`{"schemaVersion": 99, "steps": []}`))
+	if err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Errorf("DecodePlan accepted schema version 99: %v", err)
+	}
+}
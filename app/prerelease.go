@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// PrereleaseUsage is a prerelease version of a package and the repos on it.
+type PrereleaseUsage struct {
+	Package string   `json:"package"`
+	Version string   `json:"version"`
+	Channel string   `json:"channel"`
+	Repos   []string `json:"repos"`
+}
+
+// channel returns the prerelease channel of v: empty for a stable release,
+// otherwise the first identifier of its prerelease without trailing digits,
+// so that 2.0.0-beta.3 and 2.0.0-beta4 are both "beta". Build metadata plays
+// no part.
+func channel(v *semver.Version) string {
+	if v.Prerelease() == "" {
+		return ""
+	}
+	first := strings.SplitN(v.Prerelease(), ".", 2)[0]
+	name := strings.TrimRight(first, "0123456789")
+	if name == "" {
+		// numeric prereleases such as 1.0.0-0
+		return "prerelease"
+	}
+
+	return strings.TrimRight(name, "-")
+}
+
+// unifyChannels runs the strategy for package name once per prerelease
+// channel, with stable releases as a channel of their own, so that nothing
+// moves between channels. With opts.AllowPrerelease all versions are unified
+// together. Versions that are not valid semver go with the stable ones.
+func unifyChannels(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	strategy := strategies[opts.strategyFor(name)]
+	if opts.AllowPrerelease {
+		return strategy(ctx, result, name, opts)
+	}
+
+	pkg := result.Inventory.Packages[name]
+	groups := make(map[string]map[string][]string)
+	for version, repos := range pkg.Versions {
+		ch := ""
+		if v, err := semver.NewVersion(version); err == nil {
+			ch = channel(v)
+		}
+		if groups[ch] == nil {
+			groups[ch] = make(map[string][]string)
+		}
+		groups[ch][version] = repos
+	}
+	for _, ch := range sortedKeys(groups) {
+		group := groups[ch]
+		if len(group) < 2 {
+			continue
+		}
+		versions := sortedKeys(group)
+
+		// the strategy works on a copy of the package holding this channel
+		// only, which is then put back
+		sub := pkg
+		sub.Versions = group
+		result.Inventory.Packages[name] = sub
+		opts.channel = ch
+		err := strategy(ctx, result, name, opts)
+		result.Inventory.Packages[name] = pkg
+		if err != nil {
+			return err
+		}
+
+		for _, version := range versions {
+			delete(pkg.Versions, version)
+		}
+		for version, repos := range group {
+			pkg.Versions[version] = repos
+		}
+	}
+
+	return nil
+}
+
+// prereleasesInUse lists the prerelease versions used in inv.
+func prereleasesInUse(inv *Inventory) []PrereleaseUsage {
+	var usage []PrereleaseUsage
+	for _, name := range sortedKeys(inv.Packages) {
+		versions := inv.Packages[name].Versions
+		for _, version := range sortedVersions(versions) {
+			v, err := semver.NewVersion(version)
+			if err != nil || v.Prerelease() == "" {
+				continue
+			}
+			repos := append([]string(nil), versions[version]...)
+			sort.Strings(repos)
+			usage = append(usage, PrereleaseUsage{name, version, channel(v), repos})
+		}
+	}
+
+	return usage
+}
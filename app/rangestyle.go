@@ -0,0 +1,125 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Range styles UpdateManifest writes specs in.
+const (
+	// RangeKeep keeps the operator each dependency already has. It is the
+	// default.
+	RangeKeep = "keep"
+	// RangeExact pins the exact version.
+	RangeExact = "exact"
+	// RangeTilde allows patch updates.
+	RangeTilde = "~"
+	// RangeCaret allows minor and patch updates.
+	RangeCaret = "^"
+	// RangeNpmrc writes what npm install would, following save-exact and
+	// save-prefix in the repo's .npmrc.
+	RangeNpmrc = "npmrc"
+)
+
+// UpdateConfig is the update section of the config file.
+type UpdateConfig struct {
+	// RangeStyle applies to repos and packages without a style of their own.
+	// Empty means RangeKeep.
+	RangeStyle string `json:"rangeStyle"`
+	// Repos maps repos to their range style.
+	Repos map[string]string `json:"repos"`
+	// Packages maps package names, or patterns such as "@types/*", to their
+	// range style. They win over the style of the repo.
+	Packages map[string]string `json:"packages"`
+}
+
+// UpdateOptions controls how UpdateManifest rewrites a manifest.
+type UpdateOptions struct {
+	// Exclusions are left as they are and reported in UpdateResult.Excluded.
+	Exclusions Exclusions
+	// RangeStyle is one of the Range constants. Empty means RangeKeep.
+	RangeStyle string
+	// PackageStyles maps package names, or path.Match patterns, to the range
+	// style used for them instead of RangeStyle.
+	PackageStyles map[string]string
+	// SavePrefix is the prefix npm saves versions with in the repo, used for
+	// RangeNpmrc. See Npmrc.SavePrefix.
+	SavePrefix string
+}
+
+// UpdateOptionsFor returns the options the config sets for repo.
+func (c UpdateConfig) UpdateOptionsFor(repo string) UpdateOptions {
+	style := c.RangeStyle
+	if repoStyle, exists := c.Repos[repo]; exists {
+		style = repoStyle
+	}
+
+	return UpdateOptions{RangeStyle: style, PackageStyles: c.Packages}
+}
+
+func (opts UpdateOptions) validate() error {
+	for _, style := range append([]string{opts.RangeStyle}, sortedValues(opts.PackageStyles)...) {
+		switch style {
+		case "", RangeKeep, RangeExact, RangeTilde, RangeCaret, RangeNpmrc:
+		default:
+			return fmt.Errorf("unknown range style %q", style)
+		}
+	}
+	for pattern := range opts.PackageStyles {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+		}
+	}
+
+	return opts.Exclusions.validate()
+}
+
+// simpleSpec matches a spec made of an operator and a single version, such as
+// ^1.2.3, ~1.2.3, >=1.2.3 or 1.2.3.
+var simpleSpec = regexp.MustCompile(`^\s*(\^|~|>=|<=|>|<|=)?\s*v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?)\s*$`)
+
+// specOperator returns the operator and version of spec, and false when spec
+// is not an operator and a single version, for example 1.x or >=1 <3.
+func specOperator(spec string) (string, string, bool) {
+	match := simpleSpec.FindStringSubmatch(spec)
+	if match == nil {
+		return "", "", false
+	}
+
+	return match[1], match[2], true
+}
+
+// operatorFor returns the operator to write package name with, given its
+// current spec, and why. With RangeKeep it is an error when current is not an
+// operator and a single version, as there is no operator to keep.
+func (opts UpdateOptions) operatorFor(name string, current string) (string, string, error) {
+	style := opts.RangeStyle
+	if pattern, matched := matchPattern(opts.PackageStyles, name); matched {
+		style = opts.PackageStyles[pattern]
+	}
+
+	switch style {
+	case RangeExact:
+		return "", "range style exact", nil
+	case RangeTilde, RangeCaret:
+		return style, "range style " + style, nil
+	case RangeNpmrc:
+		return opts.SavePrefix, "save prefix from .npmrc", nil
+	default:
+		op, _, ok := specOperator(current)
+		if !ok {
+			return "", "", fmt.Errorf("cannot keep the operator of %q, which is not a single version", current)
+		}
+		return op, "kept operator", nil
+	}
+}
+
+func sortedValues(m map[string]string) []string {
+	var values []string
+	for _, key := range sortedKeys(m) {
+		values = append(values, m[key])
+	}
+
+	return values
+}
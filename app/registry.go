@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPackumentTTL is how long cached packuments are used without
+	// asking the registry again.
+	DefaultPackumentTTL = time.Hour
+	packumentCacheDir   = "packuments"
+)
+
+// ErrPackageNotFound is returned when a registry does not have a package.
+var ErrPackageNotFound = errors.New("package not found")
+
+// RegistryConfig is the registry section of the config file.
+type RegistryConfig struct {
+	// CacheTTL is how long packuments are cached: a duration such as "30m",
+	// or a number of days such as "1d". Empty means an hour, "0" disables
+	// the cache.
+	CacheTTL string `json:"cacheTTL"`
+}
+
+// Packument is the document a registry serves for a package, in the
+// abbreviated form npm install uses.
+type Packument struct {
+	Name     string                      `json:"name"`
+	DistTags map[string]string           `json:"dist-tags"`
+	Versions map[string]PackumentVersion `json:"versions"`
+	Modified string                      `json:"modified,omitempty"`
+}
+
+// PackumentVersion is a published version of a package.
+type PackumentVersion struct {
+	Version string `json:"version"`
+	// Deprecated is the deprecation message, if the version is deprecated.
+	Deprecated string `json:"deprecated,omitempty"`
+}
+
+// RegistryClient fetches packuments from npm-compatible registries, picking
+// the registry and credentials for each package from Npmrc. It implements
+// VersionLister, so Unify can consider every published version.
+type RegistryClient struct {
+	Npmrc Npmrc
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Cache is nil to always ask the registry.
+	Cache *PackumentCache
+}
+
+// Packument returns the packument of package name.
+func (c *RegistryClient) Packument(ctx context.Context, name string) (*Packument, error) {
+	registry := c.Npmrc.RegistryFor(name)
+	// scoped names keep their @ but escape the slash, as npm does
+	url := registry + strings.Replace(name, "/", "%2f", 1)
+
+	var cached *cachedPackument
+	if c.Cache != nil {
+		cached = c.Cache.get(url)
+		if cached != nil && time.Since(cached.FetchedAt) < c.Cache.TTL {
+			return cached.decode()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8")
+	if auth, ok := c.Npmrc.Authorization(registry); ok {
+		req.Header.Set("Authorization", auth)
+	}
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		cached.FetchedAt = time.Now()
+		c.Cache.put(url, cached)
+		return cached.decode()
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w in %s", name, ErrPackageNotFound, registry)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("failed to fetch %s from %s: %s; check the credentials in .npmrc", name, registry, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch %s from %s: %s", name, registry, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+	fetched := &cachedPackument{FetchedAt: time.Now(), ETag: resp.Header.Get("ETag"), Body: body}
+	packument, err := fetched.decode()
+	if err != nil {
+		return nil, err
+	}
+	if c.Cache != nil {
+		c.Cache.put(url, fetched)
+	}
+
+	return packument, nil
+}
+
+// ListVersions lists the published versions of package pkg.
+func (c *RegistryClient) ListVersions(ctx context.Context, pkg string) ([]string, error) {
+	packument, err := c.Packument(ctx, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedKeys(packument.Versions), nil
+}
+
+// PackumentCache keeps packuments on disk, one file per registry and
+// package, so that repeated runs do not fetch them again.
+type PackumentCache struct {
+	Dir string
+	// TTL is how long a packument is used without asking the registry.
+	// Stale ones are revalidated with their ETag.
+	TTL time.Duration
+}
+
+// cachedPackument is a packument as fetched, with when and its ETag.
+type cachedPackument struct {
+	FetchedAt time.Time       `json:"fetchedAt"`
+	ETag      string          `json:"etag,omitempty"`
+	Body      json.RawMessage `json:"body"`
+}
+
+func (p *cachedPackument) decode() (*Packument, error) {
+	var packument Packument
+	err := json.Unmarshal(p.Body, &packument)
+	if err != nil {
+		return nil, fmt.Errorf("invalid packument: %w", err)
+	}
+
+	return &packument, nil
+}
+
+func (c *PackumentCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the cached packument at url, or nil if there is none. A
+// corrupt cache file is treated as missing.
+func (c *PackumentCache) get(url string) *cachedPackument {
+	data, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+	var cached cachedPackument
+	if json.Unmarshal(data, &cached) != nil {
+		return nil
+	}
+
+	return &cached
+}
+
+// put caches the packument at url. The cache is only an optimisation, so
+// failing to write it is not an error.
+func (c *PackumentCache) put(url string, cached *cachedPackument) {
+	data, err := json.Marshal(cached)
+	if err == nil {
+		err = os.MkdirAll(c.Dir, 0777)
+	}
+	if err == nil {
+		err = WriteFileAtomic(c.path(url), data, 0666)
+	}
+	if err != nil {
+		log.Printf("Failed to cache packument: %v\n", err)
+	}
+}
+
+// RegistryClient returns a client for the registries configured in npmrc
+// that caches packuments in the workspace.
+func (w *Workspace) RegistryClient(npmrc Npmrc) *RegistryClient {
+	client := &RegistryClient{Npmrc: npmrc}
+	ttl := DefaultPackumentTTL
+	if w.Config.Registry.CacheTTL != "" {
+		// validated by OpenWorkspace
+		ttl, _ = parseAge(w.Config.Registry.CacheTTL)
+	}
+	if ttl > 0 {
+		client.Cache = &PackumentCache{Dir: w.path(packumentCacheDir), TTL: ttl}
+	}
+
+	return client
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// registryServer serves dep and @acme/widgets, with an ETag, and records the
+// requests it gets.
+type registryServer struct {
+	requests []*http.Request
+}
+
+func (s *registryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r)
+	switch r.URL.EscapedPath() {
+	case "/dep", "/@acme%2fwidgets":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("If-None-Match") == `"v1"` {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", `"v1"`)
+	w.Write([]byte(`{"name": "dep", "dist-tags": {"latest": "1.1.0"}, "versions": {"1.0.0": {"version": "1.0.0", "deprecated": "use 1.1.0"}, "1.1.0": {"version": "1.1.0"}}}`))
+}
+
+func TestRegistryClient(t *testing.T) {
+	server := &registryServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	npmrc := Npmrc{
+		"registry": ts.URL,
+		"//" + strings.TrimPrefix(ts.URL, "http://") + "/:_authToken": "token",
+	}
+	cache := &PackumentCache{Dir: t.TempDir(), TTL: time.Hour}
+	want := &Packument{
+		Name:     "dep",
+		DistTags: map[string]string{"latest": "1.1.0"},
+		Versions: map[string]PackumentVersion{
+			"1.0.0": {Version: "1.0.0", Deprecated: "use 1.1.0"},
+			"1.1.0": {Version: "1.1.0"},
+		},
+	}
+
+	none, v1 := "", `"v1"`
+	tests := []struct {
+		name  string
+		pkg   string
+		cache *PackumentCache
+		// etag is the If-None-Match the request has, or nil when the
+		// packument must come from the cache without asking.
+		etag *string
+		err  error
+	}{
+		{name: "fetched", pkg: "dep", cache: cache, etag: &none},
+		{name: "cached", pkg: "dep", cache: cache},
+		{name: "revalidated", pkg: "dep", cache: &PackumentCache{Dir: cache.Dir}, etag: &v1},
+		{name: "scoped", pkg: "@acme/widgets", etag: &none},
+		{name: "not found", pkg: "missing", etag: &none, err: ErrPackageNotFound},
+	}
+
+	for _, test := range tests {
+		server.requests = nil
+		client := &RegistryClient{Npmrc: npmrc, Cache: test.cache}
+		packument, err := client.Packument(context.Background(), test.pkg)
+		switch {
+		case test.err != nil:
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: Packument() error = %v, want %v", test.name, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case !reflect.DeepEqual(packument, want):
+			t.Errorf("%s: Packument() = %+v, want %+v", test.name, packument, want)
+		}
+
+		if test.etag == nil {
+			if len(server.requests) > 0 {
+				t.Errorf("%s: asked the registry for a cached packument", test.name)
+			}
+			continue
+		}
+		if len(server.requests) != 1 {
+			t.Errorf("%s: %d requests, want 1", test.name, len(server.requests))
+			continue
+		}
+		header := server.requests[0].Header
+		if got := header.Get("If-None-Match"); got != *test.etag {
+			t.Errorf("%s: If-None-Match %q, want %q", test.name, got, *test.etag)
+		}
+		if got := header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("%s: Authorization %q", test.name, got)
+		}
+		if got := header.Get("Accept"); !strings.HasPrefix(got, "application/vnd.npm.install-v1+json") {
+			t.Errorf("%s: Accept %q", test.name, got)
+		}
+	}
+}
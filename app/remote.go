@@ -0,0 +1,350 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v44/github"
+)
+
+// RemoteConfig is the remote section of the config file: how update --remote
+// proposes changes on GitHub. Branch, CommitMessage, Title and Body are
+// text/template templates executed with a TemplateData.
+type RemoteConfig struct {
+	Branch        string   `json:"branch"`
+	CommitMessage string   `json:"commitMessage"`
+	Title         string   `json:"title"`
+	Body          string   `json:"body"`
+	Labels        []string `json:"labels"`
+	Reviewers     []string `json:"reviewers"`
+	TeamReviewers []string `json:"teamReviewers"`
+}
+
+// Default templates of RemoteConfig and GitConfig.
+const (
+	DefaultBranch        = "pacman/update-dependencies"
+	DefaultCommitMessage = `Update dependencies to unified versions
+
+{{range .Changes}}- {{.Package}} {{.From}} -> {{.To}}
+{{end}}`
+	DefaultRemoteTitle = "Update dependencies to unified versions"
+	DefaultRemoteBody  = `pacman updated the dependencies of {{.Repo}} to the versions used across our repos.
+
+| Package | Section | From | To |
+|---|---|---|---|
+{{range .Changes}}| {{.Package}} | {{.Section}} | ` + "`{{.From}}`" + ` | ` + "`{{.To}}`" + ` |
+{{end}}`
+)
+
+// TemplateData is what the templates of RemoteConfig and GitConfig are
+// executed with.
+type TemplateData struct {
+	// Repo is owner/name.
+	Repo    string
+	Changes []DependencyChange
+	// Files are the paths of the changed files.
+	Files []string
+}
+
+// PullRequestResult is the pull request ProposeChanges opened or updated.
+type PullRequestResult struct {
+	Number int
+	URL    string
+	Branch string
+	// Created is false when an open pull request was updated instead.
+	Created bool
+	// Unchanged is true when the branch already had the changes, so nothing
+	// was pushed and the open pull request was left as it was.
+	Unchanged bool
+}
+
+// GitHubRepo is a repo on GitHub, at the head of its default branch.
+type GitHubRepo struct {
+	Client        *github.Client
+	Owner         string
+	Name          string
+	DefaultBranch string
+	// BaseSHA is the commit at the head of DefaultBranch.
+	BaseSHA string
+
+	// files are the names of the files in the root directory.
+	files map[string]bool
+}
+
+// OpenGitHubRepo looks up repo, given as owner/name, and the head of its
+// default branch.
+func OpenGitHubRepo(ctx context.Context, client *github.Client, repo string) (*GitHubRepo, error) {
+	owner, name, found := strings.Cut(repo, "/")
+	if !found {
+		return nil, fmt.Errorf("invalid repo %q: expected owner/name", repo)
+	}
+
+	info, _, err := client.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", repo, err)
+	}
+	r := &GitHubRepo{Client: client, Owner: owner, Name: name, DefaultBranch: info.GetDefaultBranch()}
+
+	ref, _, err := client.Git.GetRef(ctx, owner, name, "heads/"+r.DefaultBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s of %s: %w", r.DefaultBranch, repo, err)
+	}
+	r.BaseSHA = ref.GetObject().GetSHA()
+
+	_, entries, _, err := client.Repositories.GetContents(ctx, owner, name, "",
+		&github.RepositoryContentGetOptions{Ref: r.BaseSHA})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", repo, err)
+	}
+	r.files = make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.GetType() == "file" {
+			r.files[entry.GetName()] = true
+		}
+	}
+
+	return r, nil
+}
+
+// FullName returns owner/name.
+func (r *GitHubRepo) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// HasFile reports whether the root directory of the repo holds a file name.
+func (r *GitHubRepo) HasFile(name string) bool {
+	return r.files[name]
+}
+
+// ReadFile reads the file name from the root directory of the repo at
+// BaseSHA. Files too large for the contents API, such as lockfiles, are
+// downloaded instead.
+func (r *GitHubRepo) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	return r.readFile(ctx, name, r.BaseSHA)
+}
+
+// readFile reads the file name from the root directory of the repo at ref.
+func (r *GitHubRepo) readFile(ctx context.Context, name string, ref string) ([]byte, error) {
+	rc, _, err := r.Client.Repositories.DownloadContents(ctx, r.Owner, r.Name, name,
+		&github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s of %s: %w", name, r.FullName(), err)
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// PackageManager returns the package manager of the repo, judged by its
+// lockfile, and false if it has none. See DetectPackageManager.
+func (r *GitHubRepo) PackageManager(ctx context.Context) (PackageManager, bool) {
+	return detectPackageManager(r.HasFile, func() []byte {
+		data, _ := r.ReadFile(ctx, "package.json")
+		return data
+	})
+}
+
+// ProposeChanges commits files, mapping paths to their new contents, on a
+// branch off the default branch and opens a pull request for it, labelled
+// and with reviewers as config says. When the branch already exists it is
+// reset to the new commit, and an open pull request from it is updated
+// rather than a second one opened. When the branch already has files,
+// nothing is pushed and an open pull request from it is left alone.
+func (r *GitHubRepo) ProposeChanges(ctx context.Context, files map[string][]byte, config RemoteConfig, data TemplateData) (*PullRequestResult, error) {
+	data.Files = sortedKeys(files)
+	branch, err := executeTemplate("branch", config.Branch, DefaultBranch, data)
+	if err != nil {
+		return nil, err
+	}
+	message, err := executeTemplate("commit message", config.CommitMessage, DefaultCommitMessage, data)
+	if err != nil {
+		return nil, err
+	}
+	title, err := executeTemplate("title", config.Title, DefaultRemoteTitle, data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := executeTemplate("body", config.Body, DefaultRemoteBody, data)
+	if err != nil {
+		return nil, err
+	}
+
+	upToDate, err := r.branchHas(ctx, branch, files)
+	if err != nil {
+		return nil, err
+	}
+	if !upToDate {
+		commit, err := r.commit(ctx, files, message)
+		if err != nil {
+			return nil, err
+		}
+		err = r.setBranch(ctx, branch, commit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &PullRequestResult{Branch: branch}
+	pulls, _, err := r.Client.PullRequests.List(ctx, r.Owner, r.Name, &github.PullRequestListOptions{
+		State: "open",
+		Head:  r.Owner + ":" + branch,
+		Base:  r.DefaultBranch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests of %s: %w", r.FullName(), err)
+	}
+
+	if len(pulls) > 0 && upToDate {
+		result.Number = pulls[0].GetNumber()
+		result.URL = pulls[0].GetHTMLURL()
+		result.Unchanged = true
+		return result, nil
+	}
+
+	var pull *github.PullRequest
+	if len(pulls) > 0 {
+		pull, _, err = r.Client.PullRequests.Edit(ctx, r.Owner, r.Name, pulls[0].GetNumber(),
+			&github.PullRequest{Title: &title, Body: &body})
+	} else {
+		pull, _, err = r.Client.PullRequests.Create(ctx, r.Owner, r.Name, &github.NewPullRequest{
+			Title: &title,
+			Head:  &branch,
+			Base:  &r.DefaultBranch,
+			Body:  &body,
+		})
+		result.Created = true
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pull request on %s: %w", r.FullName(), err)
+	}
+	result.Number = pull.GetNumber()
+	result.URL = pull.GetHTMLURL()
+
+	if len(config.Labels) > 0 {
+		_, _, err = r.Client.Issues.AddLabelsToIssue(ctx, r.Owner, r.Name, result.Number, config.Labels)
+		if err != nil {
+			return result, fmt.Errorf("failed to label %s: %w", result.URL, err)
+		}
+	}
+	if len(config.Reviewers) > 0 || len(config.TeamReviewers) > 0 {
+		_, _, err = r.Client.PullRequests.RequestReviewers(ctx, r.Owner, r.Name, result.Number, github.ReviewersRequest{
+			Reviewers:     config.Reviewers,
+			TeamReviewers: config.TeamReviewers,
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to request reviewers for %s: %w", result.URL, err)
+		}
+	}
+
+	return result, nil
+}
+
+// branchHas reports whether branch exists and already has files, mapping
+// paths to their contents.
+func (r *GitHubRepo) branchHas(ctx context.Context, branch string, files map[string][]byte) (bool, error) {
+	ref, resp, err := r.Client.Git.GetRef(ctx, r.Owner, r.Name, "heads/"+branch)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up branch %s of %s: %w", branch, r.FullName(), err)
+	}
+
+	for _, path := range sortedKeys(files) {
+		data, err := r.readFile(ctx, path, ref.GetObject().GetSHA())
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(data, files[path]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// commit creates a commit on top of BaseSHA that changes files, and returns
+// its SHA.
+func (r *GitHubRepo) commit(ctx context.Context, files map[string][]byte, message string) (string, error) {
+	base, _, err := r.Client.Git.GetCommit(ctx, r.Owner, r.Name, r.BaseSHA)
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit %s of %s: %w", r.BaseSHA, r.FullName(), err)
+	}
+
+	var entries []*github.TreeEntry
+	for _, path := range sortedKeys(files) {
+		blob, _, err := r.Client.Git.CreateBlob(ctx, r.Owner, r.Name, &github.Blob{
+			Content:  github.String(base64.StdEncoding.EncodeToString(files[path])),
+			Encoding: github.String("base64"),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to upload %s to %s: %w", path, r.FullName(), err)
+		}
+		entries = append(entries, &github.TreeEntry{
+			Path: github.String(path),
+			Mode: github.String("100644"),
+			Type: github.String("blob"),
+			SHA:  blob.SHA,
+		})
+	}
+
+	tree, _, err := r.Client.Git.CreateTree(ctx, r.Owner, r.Name, base.GetTree().GetSHA(), entries)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tree on %s: %w", r.FullName(), err)
+	}
+	commit, _, err := r.Client.Git.CreateCommit(ctx, r.Owner, r.Name, &github.Commit{
+		Message: &message,
+		Tree:    tree,
+		Parents: []*github.Commit{{SHA: &r.BaseSHA}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create commit on %s: %w", r.FullName(), err)
+	}
+
+	return commit.GetSHA(), nil
+}
+
+// setBranch points branch at commit, creating the branch if needed.
+func (r *GitHubRepo) setBranch(ctx context.Context, branch string, commit string) error {
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: &commit},
+	}
+
+	_, resp, err := r.Client.Git.GetRef(ctx, r.Owner, r.Name, "heads/"+branch)
+	switch {
+	case err == nil:
+		_, _, err = r.Client.Git.UpdateRef(ctx, r.Owner, r.Name, ref, true)
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		_, _, err = r.Client.Git.CreateRef(ctx, r.Owner, r.Name, ref)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to push branch %s to %s: %w", branch, r.FullName(), err)
+	}
+
+	return nil
+}
+
+func executeTemplate(name string, text string, fallback string, data TemplateData) (string, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v44/github"
+)
+
+// fakeGitHub serves the parts of the GitHub API ProposeChanges uses, for
+// repo o/r. branch is the head of the update branch, if it exists, with the
+// files it has, and pulls the open pull requests from it. Every request
+// changing something is recorded in writes.
+type fakeGitHub struct {
+	server *httptest.Server
+	branch map[string]string
+	pulls  []int
+	writes []string
+}
+
+func newFakeGitHub(t *testing.T, branch map[string]string, pulls []int) *fakeGitHub {
+	f := &fakeGitHub{branch: branch, pulls: pulls}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeGitHub) client(t *testing.T) *github.Client {
+	client := github.NewClient(nil)
+	base, err := url.Parse(f.server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = base
+
+	return client
+}
+
+func (f *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/repos/o/r/")
+	if r.Method != http.MethodGet {
+		f.writes = append(f.writes, r.Method+" "+path)
+	}
+
+	var reply interface{}
+	switch {
+	case r.Method == http.MethodGet && path == "git/ref/heads/"+DefaultBranch:
+		if f.branch == nil {
+			http.NotFound(w, r)
+			return
+		}
+		reply = map[string]interface{}{"ref": "refs/heads/" + DefaultBranch, "object": map[string]string{"sha": "head"}}
+	case r.Method == http.MethodGet && (path == "contents/." || path == "contents/"):
+		var entries []map[string]string
+		for _, name := range sortedKeys(f.branch) {
+			entries = append(entries, map[string]string{
+				"name":         name,
+				"type":         "file",
+				"download_url": f.server.URL + "/raw/" + name,
+			})
+		}
+		reply = entries
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/raw/"):
+		w.Write([]byte(f.branch[strings.TrimPrefix(r.URL.Path, "/raw/")]))
+		return
+	case r.Method == http.MethodGet && path == "git/commits/base":
+		reply = map[string]interface{}{"sha": "base", "tree": map[string]string{"sha": "tree"}}
+	case r.Method == http.MethodGet && path == "pulls":
+		var pulls []map[string]interface{}
+		for _, number := range f.pulls {
+			pulls = append(pulls, map[string]interface{}{"number": number, "html_url": "https://github.com/o/r/pull/1"})
+		}
+		reply = pulls
+	case r.Method == http.MethodPost && path == "pulls":
+		reply = map[string]interface{}{"number": 1, "html_url": "https://github.com/o/r/pull/1"}
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "pulls/"):
+		reply = map[string]interface{}{"number": 1, "html_url": "https://github.com/o/r/pull/1"}
+	case r.Method == http.MethodPost || r.Method == http.MethodPatch:
+		reply = map[string]interface{}{"sha": "new", "ref": "refs/heads/" + DefaultBranch}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+func TestProposeChanges(t *testing.T) {
+	files := map[string][]byte{"package.json": manifestWith("^4.17.21")}
+
+	tests := []struct {
+		name       string
+		branch     map[string]string
+		pulls      []int
+		want       PullRequestResult
+		wantWrites []string
+	}{
+		{
+			name: "new branch",
+			want: PullRequestResult{Number: 1, Created: true},
+			wantWrites: []string{
+				"POST git/blobs", "POST git/trees", "POST git/commits", "POST git/refs", "POST pulls",
+			},
+		},
+		{
+			name:   "branch differs",
+			branch: map[string]string{"package.json": string(manifestWith("^4.17.20"))},
+			pulls:  []int{1},
+			want:   PullRequestResult{Number: 1},
+			wantWrites: []string{
+				"POST git/blobs", "POST git/trees", "POST git/commits",
+				"PATCH git/refs/heads/" + DefaultBranch, "PATCH pulls/1",
+			},
+		},
+		{
+			name:   "branch up to date",
+			branch: map[string]string{"package.json": string(manifestWith("^4.17.21"))},
+			pulls:  []int{1},
+			want:   PullRequestResult{Number: 1, Unchanged: true},
+		},
+		{
+			name:       "branch up to date without a pull request",
+			branch:     map[string]string{"package.json": string(manifestWith("^4.17.21"))},
+			want:       PullRequestResult{Number: 1, Created: true},
+			wantWrites: []string{"POST pulls"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, test.branch, test.pulls)
+			repo := &GitHubRepo{Client: fake.client(t), Owner: "o", Name: "r", DefaultBranch: "main", BaseSHA: "base"}
+
+			result, err := repo.ProposeChanges(context.Background(), files, RemoteConfig{}, TemplateData{Repo: "o/r"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.want.Branch = DefaultBranch
+			test.want.URL = "https://github.com/o/r/pull/1"
+			if *result != test.want {
+				t.Errorf("got %+v, want %+v", *result, test.want)
+			}
+			if !reflect.DeepEqual(fake.writes, test.wantWrites) {
+				t.Errorf("writes %v, want %v", fake.writes, test.wantWrites)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+)
+
+// Rules FixSections decides the section of a dependency by.
+const (
+	// SectionRuleConfig uses the sections the config file sets for packages.
+	SectionRuleConfig = "config"
+	// SectionRuleImports uses how the repo's source imports the package. See
+	// ScanImports.
+	SectionRuleImports = "imports"
+	// SectionRuleMajority uses the section most repos list the package in.
+	SectionRuleMajority = "majority"
+)
+
+// SectionsConfig is the sections section of the config file.
+type SectionsConfig struct {
+	// Rules are tried in order until one decides; the default is config,
+	// then majority.
+	Rules []string `json:"rules"`
+	// Packages maps package names, or patterns such as "eslint-*", to the
+	// section they belong in.
+	Packages map[string]string `json:"packages"`
+}
+
+// SectionOptions controls how FixSections moves dependencies.
+type SectionOptions struct {
+	// Rules are the SectionRule constants, tried in order. Empty means config, then
+	// majority.
+	Rules []string
+	// Packages maps package names, or path.Match patterns, to their section,
+	// for SectionRuleConfig.
+	Packages map[string]string
+	// Imports maps the packages the repo's source imports to whether it
+	// imports them at runtime, for SectionRuleImports. Nil skips the rule.
+	Imports map[string]bool
+	// Exclusions are left where they are.
+	Exclusions Exclusions
+}
+
+// SectionOptions returns the options the config sets.
+func (c SectionsConfig) SectionOptions() SectionOptions {
+	return SectionOptions{Rules: c.Rules, Packages: c.Packages}
+}
+
+func (opts SectionOptions) validate() error {
+	for _, rule := range opts.Rules {
+		switch rule {
+		case SectionRuleConfig, SectionRuleImports, SectionRuleMajority:
+		default:
+			return fmt.Errorf("unknown section rule %q", rule)
+		}
+	}
+	for pattern, section := range opts.Packages {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+		}
+		if section != SectionDependencies && section != SectionDevDependencies {
+			return fmt.Errorf("invalid section %q for %s", section, pattern)
+		}
+	}
+
+	return opts.Exclusions.validate()
+}
+
+// UsesImports reports whether the options consult the repo's imports, which
+// have to be scanned first.
+func (opts SectionOptions) UsesImports() bool {
+	return contains(opts.Rules, SectionRuleImports)
+}
+
+// FixSections moves every dependency of repo's manifest that is in the wrong
+// section, dependencies or devDependencies, to the right one, as decided by
+// the first of opts.Rules with an opinion. Each move is logged and returned
+// as a change whose reason says why. Like UpdateManifest, it only rewrites
+// the bytes that change.
+func FixSections(inv *Inventory, repo string, manifest []byte, opts SectionOptions) (*UpdateResult, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Rules) == 0 {
+		opts.Rules = []string{SectionRuleConfig, SectionRuleMajority}
+	}
+
+	var pkgDeps PackageDependencies
+	err = json.Unmarshal(manifest, &pkgDeps)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing package.json for %s: %w", repo, err)
+	}
+
+	result := &UpdateResult{Repo: repo, Manifest: manifest}
+	for _, section := range []string{SectionDependencies, SectionDevDependencies} {
+		deps, other, otherDeps := pkgDeps.Dependencies, SectionDevDependencies, pkgDeps.DevDependencies
+		if section == SectionDevDependencies {
+			deps, other, otherDeps = pkgDeps.DevDependencies, SectionDependencies, pkgDeps.Dependencies
+		}
+
+		for _, name := range sortedKeys(deps) {
+			if _, listedTwice := otherDeps[name]; listedTwice {
+				continue
+			}
+			if reason, ignored := opts.Exclusions.ignoredPackage(name); ignored {
+				result.Excluded = append(result.Excluded, Exclusion{Package: name, Reason: reason})
+				continue
+			}
+			if reason, ignored := opts.Exclusions.Ignore.Repos[repo]; ignored {
+				result.Excluded = append(result.Excluded, Exclusion{Package: name, Repo: repo, Reason: reasonOr(reason, "repo is ignored")})
+				continue
+			}
+
+			target, why := opts.sectionFor(inv, name)
+			if target != other {
+				continue
+			}
+			log.Printf("Moving %s in %s from %s to %s (%s)\n", name, repo, section, target, why)
+			result.Manifest, err = removeMember(result.Manifest, section, name)
+			if err == nil {
+				result.Manifest, err = insertDependency(result.Manifest, target, name, deps[name])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error updating package.json for %s: %w", repo, err)
+			}
+			result.Changes = append(result.Changes, DependencyChange{
+				Package: name,
+				Section: target,
+				From:    deps[name],
+				To:      deps[name],
+				Reason:  "moved from " + section + ": " + why,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// sectionFor returns the section package name belongs in and why, according
+// to the first rule with an opinion, or an empty string if none has one.
+func (opts SectionOptions) sectionFor(inv *Inventory, name string) (string, string) {
+	for _, rule := range opts.Rules {
+		switch rule {
+		case SectionRuleConfig:
+			if pattern, matched := matchPattern(opts.Packages, name); matched {
+				return opts.Packages[pattern], "config rule " + pattern
+			}
+		case SectionRuleImports:
+			runtime, imported := opts.Imports[name]
+			switch {
+			case imported && runtime:
+				return SectionDependencies, "imported by source"
+			case imported:
+				return SectionDevDependencies, "only imported by tests and tooling"
+			}
+		case SectionRuleMajority:
+			if section, why := majoritySection(inv, name); section != "" {
+				return section, why
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// majoritySection returns the section more than half of the repos declaring
+// package name list it in, and why, or an empty string if there is none.
+func majoritySection(inv *Inventory, name string) (string, string) {
+	dev, total := 0, 0
+	for _, info := range inv.Repos {
+		if _, exists := info.Dependencies[name]; exists {
+			total++
+		}
+		if _, exists := info.DevDependencies[name]; exists {
+			dev++
+			total++
+		}
+	}
+
+	switch {
+	case 2*dev > total:
+		return SectionDevDependencies, fmt.Sprintf("in %s in %d of %d repos", SectionDevDependencies, dev, total)
+	case 2*(total-dev) > total:
+		return SectionDependencies, fmt.Sprintf("in %s in %d of %d repos", SectionDependencies, total-dev, total)
+	}
+
+	return "", ""
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+// sectionManifests has jest in devDependencies in most repos, lodash in
+// dependencies in most, and react and typescript only in repo a.
+var sectionManifests = map[string][]byte{
+	"a": []byte(`{"dependencies": {"jest": "^29.0.0", "lodash": "^4.17.21", "react": "^18.0.0"}, "devDependencies": {"typescript": "^5.0.0"}}`),
+	"b": []byte(`{"dependencies": {"lodash": "^4.17.21"}, "devDependencies": {"jest": "^29.0.0"}}`),
+	"c": []byte(`{"devDependencies": {"jest": "^29.0.0", "lodash": "^4.17.21"}}`),
+}
+
+func TestFixSections(t *testing.T) {
+	inv := inventoryOf(t, sectionManifests)
+	jest := DependencyChange{Package: "jest", Section: SectionDevDependencies, From: "^29.0.0", To: "^29.0.0",
+		Reason: "moved from dependencies: in devDependencies in 2 of 3 repos"}
+
+	tests := []struct {
+		name     string
+		opts     SectionOptions
+		changes  []DependencyChange
+		excluded []Exclusion
+		err      string
+	}{
+		{
+			name:    "majority",
+			changes: []DependencyChange{jest},
+		},
+		{
+			name: "config first",
+			opts: SectionOptions{Packages: map[string]string{"type*": SectionDependencies, "jest": SectionDependencies}},
+			changes: []DependencyChange{{Package: "typescript", Section: SectionDependencies, From: "^5.0.0", To: "^5.0.0",
+				Reason: "moved from devDependencies: config rule type*"}},
+		},
+		{
+			name: "imports",
+			opts: SectionOptions{
+				Rules:   []string{SectionRuleImports},
+				Imports: map[string]bool{"jest": true, "lodash": false, "typescript": true},
+			},
+			changes: []DependencyChange{
+				{Package: "lodash", Section: SectionDevDependencies, From: "^4.17.21", To: "^4.17.21",
+					Reason: "moved from dependencies: only imported by tests and tooling"},
+				{Package: "typescript", Section: SectionDependencies, From: "^5.0.0", To: "^5.0.0",
+					Reason: "moved from devDependencies: imported by source"},
+			},
+		},
+		{
+			name:     "ignored package",
+			opts:     SectionOptions{Exclusions: Exclusions{Ignore: IgnoreConfig{Packages: map[string]string{"jest": "pinned by the test setup"}}}},
+			excluded: []Exclusion{{Package: "jest", Reason: "pinned by the test setup"}},
+		},
+		{
+			name: "unknown rule",
+			opts: SectionOptions{Rules: []string{"alphabetical"}},
+			err:  `unknown section rule "alphabetical"`,
+		},
+		{
+			name: "invalid section",
+			opts: SectionOptions{Packages: map[string]string{"jest": "peerDependencies"}},
+			err:  `invalid section "peerDependencies" for jest`,
+		},
+	}
+
+	for _, test := range tests {
+		result, err := FixSections(inv, "a", sectionManifests["a"], test.opts)
+		if test.err != "" || err != nil {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: FixSections() error = %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(result.Changes, test.changes) {
+			t.Errorf("%s: changes %+v, want %+v", test.name, result.Changes, test.changes)
+		}
+		if !reflect.DeepEqual(result.Excluded, test.excluded) {
+			t.Errorf("%s: excluded %+v, want %+v", test.name, result.Excluded, test.excluded)
+		}
+		if len(result.Changes) == 0 && string(result.Manifest) != string(sectionManifests["a"]) {
+			t.Errorf("%s: the manifest changed without changes: %s", test.name, result.Manifest)
+		}
+	}
+}
+
+func TestMajoritySection(t *testing.T) {
+	inv := inventoryOf(t, map[string][]byte{
+		"a": []byte(`{"dependencies": {"lodash": "1.0.0", "split": "1.0.0"}, "devDependencies": {"jest": "1.0.0"}}`),
+		"b": []byte(`{"dependencies": {"lodash": "1.0.0"}, "devDependencies": {"jest": "1.0.0", "split": "1.0.0"}}`),
+		"c": []byte(`{"dependencies": {"jest": "1.0.0"}, "devDependencies": {"jest": "1.0.0"}}`),
+	})
+
+	tests := []struct {
+		name    string
+		section string
+		why     string
+	}{
+		{"lodash", SectionDependencies, "in dependencies in 2 of 2 repos"},
+		// listed twice in c, which counts for both sections
+		{"jest", SectionDevDependencies, "in devDependencies in 3 of 4 repos"},
+		{"split", "", ""},
+		{"unknown", "", ""},
+	}
+	for _, test := range tests {
+		section, why := majoritySection(inv, test.name)
+		if section != test.section || why != test.why {
+			t.Errorf("majoritySection(%q) = %q, %q, want %q, %q", test.name, section, why, test.section, test.why)
+		}
+	}
+}
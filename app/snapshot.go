@@ -0,0 +1,369 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	snapshotDirName = "snapshots"
+	timestampFormat = "20060102150405"
+	// CurrentSnapshot names the live state file when diffing.
+	CurrentSnapshot = "current"
+)
+
+// InventoryDiff describes how the inventory changed between two snapshots.
+type InventoryDiff struct {
+	From            string              `json:"from"`
+	To              string              `json:"to"`
+	FromGeneratedAt time.Time           `json:"fromGeneratedAt"`
+	ToGeneratedAt   time.Time           `json:"toGeneratedAt"`
+	AddedPackages   []PackageChange     `json:"addedPackages"`
+	RemovedPackages []PackageChange     `json:"removedPackages"`
+	VersionChanges  []RepoVersionChange `json:"versionChanges"`
+	AddedVariants   []VariantChange     `json:"addedVariants"`
+	RemovedVariants []VariantChange     `json:"removedVariants"`
+}
+
+// PackageChange is a package that only exists on one side of a diff.
+type PackageChange struct {
+	Name     string              `json:"name"`
+	Versions map[string][]string `json:"versions"`
+}
+
+// RepoVersionChange is a repo whose version of a package changed. From is
+// empty when the repo started using the package and To is empty when it
+// stopped.
+type RepoVersionChange struct {
+	Package string `json:"package"`
+	Repo    string `json:"repo"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// VariantChange is a version of a package that appeared or disappeared.
+type VariantChange struct {
+	Package string   `json:"package"`
+	Version string   `json:"version"`
+	Repos   []string `json:"repos"`
+}
+
+// SaveSnapshot keeps a copy of inv in the snapshots directory, named after
+// the time it was generated, and prunes old snapshots as the backups config
+// says. Snapshots generated within the same second get a numeric suffix.
+func (w *Workspace) SaveSnapshot(inv *Inventory) error {
+	err := os.MkdirAll(w.path(snapshotDirName), 0777)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", snapshotDirName, err)
+	}
+
+	data, err := EncodeInventory(inv)
+	if err != nil {
+		return err
+	}
+
+	stamp := inv.GeneratedAt.Format(timestampFormat)
+	name := stamp
+	for i := 2; ; i++ {
+		_, err := os.Stat(filepath.Join(w.path(snapshotDirName), name+".json"))
+		if os.IsNotExist(err) {
+			break
+		}
+		name = stamp + "-" + strconv.Itoa(i)
+	}
+
+	path := filepath.Join(w.path(snapshotDirName), name+".json")
+	err = WriteFileAtomic(path, data, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot %s: %w", path, err)
+	}
+
+	return w.pruneSnapshots(name)
+}
+
+// pruneSnapshots removes the snapshots the backups config no longer retains,
+// except saved, the one just saved. Snapshots not named by a timestamp, such
+// as ones copied in by hand, are left alone.
+func (w *Workspace) pruneSnapshots(saved string) error {
+	names, err := w.ListSnapshots()
+	if err != nil {
+		return err
+	}
+
+	var snapshots []string
+	times := make(map[string]time.Time)
+	for _, name := range names {
+		if t, _, ok := parseSnapshotName(name); ok {
+			snapshots = append(snapshots, name)
+			times[name] = t
+		}
+	}
+	for i, name := range snapshots {
+		if name == saved || !w.Config.Backups.expired(i, len(snapshots), times[name]) {
+			continue
+		}
+		err := os.Remove(filepath.Join(w.path(snapshotDirName), name+".json"))
+		if err != nil {
+			log.Println("Failed to prune snapshot", name, err)
+		}
+	}
+
+	return nil
+}
+
+// ListSnapshots returns the names of the stored snapshots, oldest first.
+func (w *Workspace) ListSnapshots() ([]string, error) {
+	entries, err := os.ReadDir(w.path(snapshotDirName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, seqi, oki := parseSnapshotName(names[i])
+		tj, seqj, okj := parseSnapshotName(names[j])
+		if !oki || !okj || !ti.Equal(tj) {
+			return names[i] < names[j]
+		}
+		return seqi < seqj
+	})
+
+	return names, nil
+}
+
+// parseSnapshotName returns the time a snapshot named by SaveSnapshot was
+// generated, and its order among those generated within the same second.
+func parseSnapshotName(name string) (time.Time, int, bool) {
+	stamp, suffix, hasSuffix := strings.Cut(name, "-")
+	t, err := time.ParseInLocation(timestampFormat, stamp, time.UTC)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	if !hasSuffix {
+		return t, 0, true
+	}
+	seq, err := strconv.Atoi(suffix)
+
+	return t, seq, err == nil
+}
+
+// LoadSnapshot loads a snapshot by name. The name may be a snapshot
+// timestamp, "current" for the live state file, or a path to a state file.
+func (w *Workspace) LoadSnapshot(name string) (*Inventory, error) {
+	if name == CurrentSnapshot {
+		return w.LoadInventory()
+	}
+
+	path := filepath.Join(w.path(snapshotDirName), strings.TrimSuffix(name, ".json")+".json")
+	if !IsValidFile(path) {
+		path = name
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unknown snapshot %s: %w", name, err)
+	}
+
+	inv, err := DecodeInventory(data)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", name, err)
+	}
+
+	return inv, nil
+}
+
+// Diff compares two snapshots. See LoadSnapshot for the accepted names.
+func (w *Workspace) Diff(from string, to string) (*InventoryDiff, error) {
+	a, err := w.LoadSnapshot(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := w.LoadSnapshot(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffInventories(a, b)
+	diff.From = from
+	diff.To = to
+
+	return diff, nil
+}
+
+// DiffInventories reports how b differs from a.
+func DiffInventories(a *Inventory, b *Inventory) *InventoryDiff {
+	diff := &InventoryDiff{
+		FromGeneratedAt: a.GeneratedAt,
+		ToGeneratedAt:   b.GeneratedAt,
+		AddedPackages:   []PackageChange{},
+		RemovedPackages: []PackageChange{},
+		VersionChanges:  []RepoVersionChange{},
+		AddedVariants:   []VariantChange{},
+		RemovedVariants: []VariantChange{},
+	}
+
+	for _, name := range sortedKeys(a.Packages, b.Packages) {
+		before, inA := a.Packages[name]
+		after, inB := b.Packages[name]
+
+		switch {
+		case !inA:
+			diff.AddedPackages = append(diff.AddedPackages, PackageChange{Name: name, Versions: after.Versions})
+			continue
+		case !inB:
+			diff.RemovedPackages = append(diff.RemovedPackages, PackageChange{Name: name, Versions: before.Versions})
+			continue
+		}
+
+		for _, version := range sortedKeys(after.Versions) {
+			if _, exists := before.Versions[version]; !exists {
+				diff.AddedVariants = append(diff.AddedVariants, VariantChange{name, version, after.Versions[version]})
+			}
+		}
+		for _, version := range sortedKeys(before.Versions) {
+			if _, exists := after.Versions[version]; !exists {
+				diff.RemovedVariants = append(diff.RemovedVariants, VariantChange{name, version, before.Versions[version]})
+			}
+		}
+
+		beforeRepos := repoVersions(before)
+		afterRepos := repoVersions(after)
+		for _, repo := range sortedKeys(beforeRepos, afterRepos) {
+			if beforeRepos[repo] != afterRepos[repo] {
+				diff.VersionChanges = append(diff.VersionChanges, RepoVersionChange{
+					Package: name,
+					Repo:    repo,
+					From:    beforeRepos[repo],
+					To:      afterRepos[repo],
+				})
+			}
+		}
+	}
+
+	return diff
+}
+
+// repoVersions maps each repo using pkg to the version it uses. A repo
+// listing several versions gets them joined with a comma.
+func repoVersions(pkg Package) map[string]string {
+	versions := make(map[string][]string)
+	for version, repos := range pkg.Versions {
+		for _, repo := range repos {
+			versions[repo] = append(versions[repo], version)
+		}
+	}
+
+	joined := make(map[string]string)
+	for repo, vs := range versions {
+		sort.Strings(vs)
+		joined[repo] = strings.Join(vs, ",")
+	}
+
+	return joined
+}
+
+func sortedKeys[V any](maps ...map[string]V) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// WriteDiffJSON writes diff as indented JSON.
+func WriteDiffJSON(w io.Writer, diff *InventoryDiff) error {
+	data, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// WriteDiffText writes diff in a human readable form.
+func WriteDiffText(w io.Writer, diff *InventoryDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Comparing %s (%s) to %s (%s)\n", diff.From, diff.FromGeneratedAt.Format(time.RFC3339),
+		diff.To, diff.ToGeneratedAt.Format(time.RFC3339))
+
+	if len(diff.AddedPackages)+len(diff.RemovedPackages)+len(diff.VersionChanges)+
+		len(diff.AddedVariants)+len(diff.RemovedVariants) == 0 {
+		fmt.Fprintln(tw, "\nNo changes")
+		return tw.Flush()
+	}
+
+	if len(diff.AddedPackages) > 0 {
+		fmt.Fprintln(tw, "\nAdded packages:")
+		for _, pkg := range diff.AddedPackages {
+			fmt.Fprintf(tw, "  + %s\t%s\n", pkg.Name, formatVersions(pkg.Versions))
+		}
+	}
+	if len(diff.RemovedPackages) > 0 {
+		fmt.Fprintln(tw, "\nRemoved packages:")
+		for _, pkg := range diff.RemovedPackages {
+			fmt.Fprintf(tw, "  - %s\t%s\n", pkg.Name, formatVersions(pkg.Versions))
+		}
+	}
+	if len(diff.VersionChanges) > 0 {
+		fmt.Fprintln(tw, "\nVersion changes:")
+		for _, change := range diff.VersionChanges {
+			fmt.Fprintf(tw, "  %s\t%s\t%s -> %s\n", change.Package, change.Repo, orNone(change.From), orNone(change.To))
+		}
+	}
+	if len(diff.AddedVariants) > 0 {
+		fmt.Fprintln(tw, "\nNew version variants:")
+		for _, variant := range diff.AddedVariants {
+			fmt.Fprintf(tw, "  + %s@%s\t%s\n", variant.Package, variant.Version, strings.Join(variant.Repos, ", "))
+		}
+	}
+	if len(diff.RemovedVariants) > 0 {
+		fmt.Fprintln(tw, "\nEliminated version variants:")
+		for _, variant := range diff.RemovedVariants {
+			fmt.Fprintf(tw, "  - %s@%s\t%s\n", variant.Package, variant.Version, strings.Join(variant.Repos, ", "))
+		}
+	}
+
+	return tw.Flush()
+}
+
+func formatVersions(versions map[string][]string) string {
+	var parts []string
+	for _, version := range sortedKeys(versions) {
+		parts = append(parts, version+" ("+strings.Join(versions[version], ", ")+")")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func orNone(version string) string {
+	if version == "" {
+		return "(none)"
+	}
+
+	return version
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSaveSnapshotRetention(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	tests := []struct {
+		name   string
+		config BackupConfig
+		// ages are how long ago the snapshots saved in turn were generated.
+		ages []time.Duration
+		want []time.Duration
+	}{
+		{
+			name:   "keep",
+			config: BackupConfig{Keep: 2},
+			ages:   []time.Duration{4 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour},
+			want:   []time.Duration{2 * time.Hour, time.Hour},
+		},
+		{
+			name:   "max age",
+			config: BackupConfig{MaxAge: "1d"},
+			ages:   []time.Duration{72 * time.Hour, 36 * time.Hour, 12 * time.Hour, time.Hour},
+			want:   []time.Duration{12 * time.Hour, time.Hour},
+		},
+		{
+			name:   "newest is kept",
+			config: BackupConfig{MaxAge: "1h"},
+			ages:   []time.Duration{4 * time.Hour, 3 * time.Hour},
+			want:   []time.Duration{3 * time.Hour},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, err := OpenWorkspace(t.TempDir(), &Config{Backups: test.config})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// snapshots not named by a timestamp are left alone
+			err = os.MkdirAll(w.path(snapshotDirName), 0777)
+			if err == nil {
+				err = os.WriteFile(filepath.Join(w.path(snapshotDirName), "before-migration.json"), []byte("{}"), 0666)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, age := range test.ages {
+				inv := newInventory(map[string]RepoInfo{}, map[string]Package{})
+				inv.GeneratedAt = now.Add(-age)
+				if err := w.SaveSnapshot(inv); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want := []string{}
+			for _, age := range test.want {
+				want = append(want, now.Add(-age).Format(timestampFormat))
+			}
+			want = append(want, "before-migration")
+			got, err := w.ListSnapshots()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalStrings(got, want) {
+				t.Errorf("snapshots %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSaveSnapshotSameSecond(t *testing.T) {
+	w, err := OpenWorkspace(t.TempDir(), &Config{Backups: BackupConfig{Keep: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 11; i++ {
+		inv := newInventory(map[string]RepoInfo{}, map[string]Package{})
+		inv.GeneratedAt = generated
+		if err := w.SaveSnapshot(inv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stamp := generated.Format(timestampFormat)
+	want := []string{stamp}
+	for i := 2; i <= 11; i++ {
+		want = append(want, stamp+"-"+strconv.Itoa(i))
+	}
+	got, err := w.ListSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(got, want) {
+		t.Errorf("snapshots %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,204 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// StateSchemaVersion is the version of the state file format written by this
+// build. Bump it whenever the layout of Inventory changes and add a step to
+// migrateInventory so older files keep loading.
+const StateSchemaVersion = 2
+
+const (
+	stateFileName       = "pacman-state.json"
+	legacyStateFileName = "packages.gob"
+)
+
+// Sources a repo manifest can be read from.
+const (
+	SourceDir    = "dir"
+	SourceGithub = "github"
+	// SourceUnknown marks repos carried over from a packages.gob file, which
+	// never recorded where a manifest came from.
+	SourceUnknown = "unknown"
+)
+
+// Inventory is every dependency of every parsed repo. It is what parse
+// builds, what unify and update work from, and what the state file holds.
+type Inventory struct {
+	SchemaVersion int                 `json:"schemaVersion"`
+	GeneratedAt   time.Time           `json:"generatedAt"`
+	Repos         map[string]RepoInfo `json:"repos"`
+	Packages      map[string]Package  `json:"packages"`
+}
+
+// RepoInfo records where the manifest of a parsed repo came from, and the
+// dependency specs it declares.
+type RepoInfo struct {
+	Source          string            `json:"source"`
+	Location        string            `json:"location"`
+	ManifestPath    string            `json:"manifestPath"`
+	Commit          string            `json:"commit,omitempty"`
+	Dependencies    map[string]string `json:"dependencies,omitempty"`
+	DevDependencies map[string]string `json:"devDependencies,omitempty"`
+}
+
+// DeclaredSpec returns the spec repo declares for pkg, as written in its
+// manifest, and false if it is unknown. Inventories migrated from schema
+// version 1 have no declared specs until the next parse.
+func (inv *Inventory) DeclaredSpec(repo string, pkg string) (string, bool) {
+	info := inv.Repos[repo]
+	if spec, exists := info.Dependencies[pkg]; exists {
+		return spec, true
+	}
+	spec, exists := info.DevDependencies[pkg]
+
+	return spec, exists
+}
+
+// RepoForDir returns the repo parsed from the checkout in dir, and false if
+// no repo was parsed from there.
+func (inv *Inventory) RepoForDir(dir string) (string, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, repo := range sortedKeys(inv.Repos) {
+		info := inv.Repos[repo]
+		if info.Source == SourceDir && filepath.Clean(info.Location) == abs {
+			return repo, true
+		}
+	}
+
+	return "", false
+}
+
+func newInventory(repos map[string]RepoInfo, packages map[string]Package) *Inventory {
+	return &Inventory{
+		SchemaVersion: StateSchemaVersion,
+		GeneratedAt:   time.Now().UTC(),
+		Repos:         repos,
+		Packages:      packages,
+	}
+}
+
+// Clone returns a deep copy of inv, so it can be changed without affecting
+// the original.
+func (inv *Inventory) Clone() *Inventory {
+	clone := *inv
+	clone.Repos = make(map[string]RepoInfo, len(inv.Repos))
+	for name, info := range inv.Repos {
+		clone.Repos[name] = info
+	}
+	clone.Packages = make(map[string]Package, len(inv.Packages))
+	for name, pkg := range inv.Packages {
+		versions := make(map[string][]string, len(pkg.Versions))
+		for version, repos := range pkg.Versions {
+			versions[version] = append([]string(nil), repos...)
+		}
+		pkg.Versions = versions
+		clone.Packages[name] = pkg
+	}
+
+	return &clone
+}
+
+// Fingerprint identifies the contents of inv: its repos and packages, but not
+// when it was generated, so parsing unchanged repos again keeps it the same.
+func (inv *Inventory) Fingerprint() string {
+	// maps are encoded with sorted keys, so equal inventories encode equally
+	data, err := json.Marshal(struct {
+		Repos    map[string]RepoInfo `json:"repos"`
+		Packages map[string]Package  `json:"packages"`
+	}{inv.Repos, inv.Packages})
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// EncodeInventory encodes inv in the state file format.
+func EncodeInventory(inv *Inventory) ([]byte, error) {
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inventory: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// DecodeInventory decodes a state file, migrating it from older schema
+// versions. Files written by a newer pacman are rejected.
+func DecodeInventory(data []byte) (*Inventory, error) {
+	var inv Inventory
+	err := json.Unmarshal(data, &inv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode inventory: %w", err)
+	}
+
+	if inv.SchemaVersion > StateSchemaVersion {
+		return nil, fmt.Errorf("inventory has schema version %d, but this pacman only understands up to %d; upgrade pacman",
+			inv.SchemaVersion, StateSchemaVersion)
+	}
+	err = migrateInventory(&inv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+// migrateInventory upgrades a decoded inventory in place, one schema version
+// at a time.
+func migrateInventory(inv *Inventory) error {
+	if inv.SchemaVersion < 1 {
+		return fmt.Errorf("inventory has invalid schema version %d", inv.SchemaVersion)
+	}
+	// version 2 added the specs each repo declares; they are only known
+	// after parsing again, so there is nothing to fill in
+	if inv.SchemaVersion < 2 {
+		inv.SchemaVersion = 2
+	}
+	if inv.Repos == nil {
+		inv.Repos = make(map[string]RepoInfo)
+	}
+	if inv.Packages == nil {
+		inv.Packages = make(map[string]Package)
+	}
+
+	return nil
+}
+
+// DecodeLegacyInventory converts the contents of a packages.gob file written
+// by older versions of pacman. It never recorded where manifests came from,
+// so every repo gets SourceUnknown.
+func DecodeLegacyInventory(data []byte, generatedAt time.Time) (*Inventory, error) {
+	var packages map[string]Package
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&packages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", legacyStateFileName, err)
+	}
+
+	repos := make(map[string]RepoInfo)
+	for _, pkg := range packages {
+		for _, pkgRepos := range pkg.Versions {
+			for _, repo := range pkgRepos {
+				repos[repo] = RepoInfo{Source: SourceUnknown, Location: repo, ManifestPath: "package.json"}
+			}
+		}
+	}
+
+	inv := newInventory(repos, packages)
+	inv.GeneratedAt = generatedAt.UTC()
+
+	return inv, nil
+}
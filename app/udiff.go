@@ -0,0 +1,171 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffOp is a line of a diff: kept (' '), removed ('-') or added ('+').
+type diffOp struct {
+	Kind byte
+	Line string
+	// A and B are the indexes of the line in a and b.
+	A, B int
+}
+
+// UnifiedDiff returns the differences between a and b in unified diff
+// format, as diff -u and git diff show them, labelling the sides fromName
+// and toName. It returns an empty string when a and b are equal.
+func UnifiedDiff(fromName string, toName string, a []byte, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	linesA, linesB := splitLines(a), splitLines(b)
+	ops := diffLines(linesA, linesB)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(ops); {
+		// find the next change and the end of the hunk around it; changes
+		// less than two contexts apart share a hunk
+		first := start
+		for first < len(ops) && ops[first].Kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].Kind != ' ' {
+				last = i
+			} else if i-last > 2*diffContext {
+				break
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(ops))
+
+		writeHunk(&buf, ops[from:to], linesA, linesB)
+		start = to
+	}
+
+	return buf.String()
+}
+
+func writeHunk(buf *strings.Builder, ops []diffOp, linesA []string, linesB []string) {
+	startA, startB, countA, countB := -1, -1, 0, 0
+	for _, op := range ops {
+		if op.Kind != '+' {
+			if startA < 0 {
+				startA = op.A
+			}
+			countA++
+		}
+		if op.Kind != '-' {
+			if startB < 0 {
+				startB = op.B
+			}
+			countB++
+		}
+	}
+	// an empty side is numbered after the line it follows
+	if startA < 0 {
+		startA = ops[0].A - 1
+	}
+	if startB < 0 {
+		startB = ops[0].B - 1
+	}
+
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+	for _, op := range ops {
+		buf.WriteByte(op.Kind)
+		buf.WriteString(strings.TrimSuffix(op.Line, "\n"))
+		buf.WriteByte('\n')
+		if !strings.HasSuffix(op.Line, "\n") {
+			buf.WriteString("\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start int, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits data after each newline, keeping the newlines so that a
+// missing final newline shows in the diff.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b using their longest
+// common subsequence. Manifests are small, so the quadratic table is fine.
+func diffLines(a []string, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		}
+	}
+
+	return ops
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines 1 to n, with those in replace replaced.
+func numbered(n int, replace map[int]string) string {
+	var buf strings.Builder
+	for i := 1; i <= n; i++ {
+		line, replaced := replace[i]
+		if !replaced {
+			line = strconv.Itoa(i)
+		}
+		buf.WriteString(line + "\n")
+	}
+
+	return buf.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	// the hunks are as diff -u prints them
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    numbered(3, nil),
+			b:    numbered(3, nil),
+		},
+		{
+			name: "context",
+			a:    numbered(9, nil),
+			b:    numbered(9, map[int]string{5: "five"}),
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			a:    numbered(20, nil),
+			b:    numbered(20, map[int]string{2: "two", 18: "eighteen"}),
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			name: "shared hunk",
+			a:    numbered(12, nil),
+			b:    numbered(12, map[int]string{3: "three", 9: "nine"}),
+			want: "@@ -1,12 +1,12 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n 8\n-9\n+nine\n 10\n 11\n 12\n",
+		},
+		{
+			name: "removed line",
+			a:    "1\n2\n3\n",
+			b:    "1\n3\n",
+			want: "@@ -1,3 +1,2 @@\n 1\n-2\n 3\n",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "x\n",
+			want: "@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name: "no newline at end",
+			a:    "x\n",
+			b:    "x",
+			want: "@@ -1 +1 @@\n-x\n+x\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, test := range tests {
+		want := test.want
+		if want != "" {
+			want = "--- a/package.json\n+++ b/package.json\n" + want
+		}
+		got := UnifiedDiff("a/package.json", "b/package.json", []byte(test.a), []byte(test.b))
+		if got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, want)
+		}
+	}
+}
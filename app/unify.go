@@ -0,0 +1,499 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Unify strategies.
+const (
+	// StrategyPatch folds each version into a newer one with the same minor
+	// version. It is the default.
+	StrategyPatch = "patch"
+	// StrategyMinor folds each version into a newer one with the same major
+	// version.
+	StrategyMinor = "minor"
+	// StrategyIntersect moves every repo to the highest published version
+	// satisfying the ranges all repos declare.
+	StrategyIntersect = "intersect"
+	// StrategyHighest moves every repo to the highest version in use.
+	StrategyHighest = "highest"
+	// StrategyMostUsed moves every repo to the version used by most repos,
+	// which minimises the number of repos that must change.
+	StrategyMostUsed = "most-used"
+	// StrategyLowestCommon moves every repo to the lowest published version
+	// satisfying the ranges all repos declare.
+	StrategyLowestCommon = "lowest-common"
+	// StrategyNewestWithinMajor moves every repo to the newest version in use
+	// with the same major version as its own.
+	StrategyNewestWithinMajor = "newest-within-major"
+)
+
+type strategyFunc func(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error
+
+var strategies = map[string]strategyFunc{
+	StrategyPatch:             unifyPatch,
+	StrategyMinor:             unifyMinor,
+	StrategyIntersect:         unifyIntersect,
+	StrategyHighest:           unifyHighest,
+	StrategyMostUsed:          unifyMostUsed,
+	StrategyLowestCommon:      unifyLowestCommon,
+	StrategyNewestWithinMajor: unifyNewestWithinMajor,
+}
+
+// Strategies returns the names of the unify strategies, sorted.
+func Strategies() []string {
+	return sortedKeys(strategies)
+}
+
+// UnifyConfig is the unify section of the config file.
+type UnifyConfig struct {
+	// Strategy applies to packages without a policy. Empty means
+	// StrategyPatch.
+	Strategy string `json:"strategy"`
+	// Packages maps package names, or patterns such as "@types/*", to the
+	// strategy used for them.
+	Packages map[string]string `json:"packages"`
+	// AllowPrerelease lets stable repos move onto prereleases, and
+	// prereleases across channels.
+	AllowPrerelease bool `json:"allowPrerelease"`
+}
+
+// UnifyOptions controls how Unify groups versions.
+type UnifyOptions struct {
+	// Strategy applies to packages without a policy. Empty means
+	// StrategyPatch.
+	Strategy string
+	// Policies maps package names, or path.Match patterns such as
+	// "@types/*", to the strategy used for matching packages. An exact name
+	// wins over patterns, and a longer pattern over a shorter one.
+	Policies map[string]string
+	// AllowPrerelease lets stable repos move onto prereleases, and
+	// prereleases across channels. Without it, stable releases and each
+	// prerelease channel are unified separately.
+	AllowPrerelease bool
+	// Exclusions are left as they are and reported in UnifyResult.Excluded.
+	Exclusions Exclusions
+	// Versions lists the published versions of a package. When nil, only
+	// the versions found in the inventory are considered.
+	Versions VersionLister
+
+	// channel is the prerelease channel being unified.
+	channel string
+}
+
+// strategyFor returns the strategy that applies to package name.
+func (opts UnifyOptions) strategyFor(name string) string {
+	if pattern, matched := matchPattern(opts.Policies, name); matched {
+		return opts.Policies[pattern]
+	}
+	if opts.Strategy == "" {
+		return StrategyPatch
+	}
+
+	return opts.Strategy
+}
+
+// UsesPublishedVersions reports whether the strategies of the options pick
+// from the versions Versions lists, which should then be the published ones.
+func (opts UnifyOptions) UsesPublishedVersions() bool {
+	for _, strategy := range append(sortedValues(opts.Policies), opts.Strategy) {
+		if strategy == StrategyIntersect || strategy == StrategyLowestCommon {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (opts UnifyOptions) validate() error {
+	if _, exists := strategies[opts.Strategy]; opts.Strategy != "" && !exists {
+		return fmt.Errorf("unknown unify strategy %q", opts.Strategy)
+	}
+	for pattern, strategy := range opts.Policies {
+		if _, exists := strategies[strategy]; !exists {
+			return fmt.Errorf("unknown unify strategy %q for %s", strategy, pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+		}
+	}
+
+	return opts.Exclusions.validate()
+}
+
+// VersionLister lists the published versions of a package.
+type VersionLister interface {
+	ListVersions(ctx context.Context, pkg string) ([]string, error)
+}
+
+// inventoryVersions lists the versions of a package that are in use.
+type inventoryVersions struct {
+	inv *Inventory
+}
+
+func (l inventoryVersions) ListVersions(ctx context.Context, pkg string) ([]string, error) {
+	return sortedKeys(l.inv.Packages[pkg].Versions), nil
+}
+
+// UnifyResult is the outcome of Unify.
+type UnifyResult struct {
+	// Inventory is the unified inventory. The inventory passed to Unify is
+	// left untouched.
+	Inventory *Inventory
+	Merges    []Merge
+	// Migrations are the repos that must change version for their manifests
+	// to match the unified inventory.
+	Migrations []Migration
+	// Conflicts are packages that could not be unified because no single
+	// version satisfies every repo.
+	Conflicts []Conflict
+	// Excluded are the packages and repos left alone because of
+	// UnifyOptions.Exclusions.
+	Excluded []Exclusion
+	// Prereleases are the prereleases still in use after unifying.
+	Prereleases []PrereleaseUsage
+}
+
+// Merge records a version of a package being folded into another.
+type Merge struct {
+	Package string `json:"package"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	// Reason says why To was chosen.
+	Reason string `json:"reason"`
+}
+
+// Conflict is a package whose declared ranges have no version in common.
+type Conflict struct {
+	Package string      `json:"package"`
+	Reason  string      `json:"reason"`
+	Ranges  []RepoRange `json:"ranges"`
+}
+
+// RepoRange is the range a repo declares for a package.
+type RepoRange struct {
+	Repo    string `json:"repo"`
+	Range   string `json:"range"`
+	Version string `json:"version"`
+}
+
+// Unify collapses the versions of each package in inv, using the strategy
+// opts selects for it.
+func Unify(ctx context.Context, inv *Inventory, opts UnifyOptions) (*UnifyResult, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UnifyResult{Inventory: inv.Clone()}
+	if opts.Versions == nil {
+		opts.Versions = inventoryVersions{inv}
+	}
+
+	for _, name := range sortedKeys(result.Inventory.Packages) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pkg := result.Inventory.Packages[name]
+		if len(pkg.Versions) == 1 {
+			continue
+		}
+		if reason, ignored := opts.Exclusions.ignoredPackage(name); ignored {
+			result.Excluded = append(result.Excluded, Exclusion{Package: name, Reason: reason})
+			continue
+		}
+
+		// the strategy only sees the repos it may move
+		held := make(map[string][]string)
+		var excluded []Exclusion
+		for _, version := range sortedVersions(pkg.Versions) {
+			var free []string
+			for _, repo := range pkg.Versions[version] {
+				if reason, isHeld := opts.Exclusions.heldRepo(name, repo, version); isHeld {
+					held[version] = append(held[version], repo)
+					excluded = append(excluded, Exclusion{name, repo, version, reason})
+				} else {
+					free = append(free, repo)
+				}
+			}
+			if len(free) == 0 {
+				delete(pkg.Versions, version)
+			} else {
+				pkg.Versions[version] = free
+			}
+		}
+
+		if len(pkg.Versions) > 1 {
+			err := unifyChannels(ctx, result, name, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for version, repos := range held {
+			pkg.Versions[version] = append(pkg.Versions[version], repos...)
+			sort.Strings(pkg.Versions[version])
+		}
+		// a held repo that ends up on the same version as the rest is no
+		// news
+		if len(pkg.Versions) > 1 {
+			result.Excluded = append(result.Excluded, excluded...)
+		}
+	}
+	result.Migrations = buildMigrations(inv, result.Inventory)
+	result.Prereleases = prereleasesInUse(result.Inventory)
+
+	return result, nil
+}
+
+func unifyPatch(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	unifySimilar(result, name, false)
+	return nil
+}
+
+func unifyMinor(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	unifySimilar(result, name, true)
+	return nil
+}
+
+func unifySimilar(result *UnifyResult, name string, isMinor bool) {
+	var modifier, why string
+
+	pkg := result.Inventory.Packages[name]
+
+	if isMinor {
+		modifier = "^"
+		why = "highest version with the same major version"
+	} else {
+		modifier = "~"
+		why = "highest version with the same minor version"
+	}
+
+	// sortedVersions orders versions that differ only in build metadata by
+	// string, so the result does not depend on map order
+	versions := make([]*semver.Version, 0, len(pkg.Versions))
+	for _, version := range sortedVersions(pkg.Versions) {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			log.Printf("Failed to parse version %s for package %s\n", version, pkg.Name)
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	// each version moves straight to the highest similar version, so chains
+	// such as 4.17.1 -> 4.17.3 -> 4.17.19 end on 4.17.19 in one step
+	for i := 0; i < len(versions); i++ {
+		c, err := semver.NewConstraint(modifier + versions[i].String())
+		if err != nil {
+			log.Printf("Failed to parse contraint %s\n", versions[i])
+			continue
+		}
+		target := -1
+		for j := i + 1; j < len(versions); j++ {
+			if valid, _ := c.Validate(versions[j]); valid {
+				target = j
+			}
+		}
+		if target < 0 {
+			continue
+		}
+
+		from, to := versions[i].Original(), versions[target].Original()
+		log.Printf("Found similar version %s to %s for package %s\n", to, from, pkg.Name)
+		mergeVersion(pkg, from, to)
+		result.Merges = append(result.Merges, Merge{pkg.Name, from, to, why})
+	}
+}
+
+// unifyIntersect moves every repo using the package to the highest published
+// version that satisfies the range each of them declares. When there is no
+// such version the package is left alone and reported as a conflict.
+func unifyIntersect(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	return unifyWithinRanges(ctx, result, name, opts, true)
+}
+
+// unifyLowestCommon is unifyIntersect, but picks the lowest version
+// satisfying every declared range, which keeps upgrades as small as possible.
+func unifyLowestCommon(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	return unifyWithinRanges(ctx, result, name, opts, false)
+}
+
+func unifyWithinRanges(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions, highest bool) error {
+	inv := result.Inventory
+	pkg := inv.Packages[name]
+
+	var ranges []RepoRange
+	for _, version := range sortedVersions(pkg.Versions) {
+		for _, repo := range pkg.Versions[version] {
+			spec, known := inv.DeclaredSpec(repo, name)
+			if !known {
+				// without a declared range, all we know is the version in use
+				spec = version
+			}
+			ranges = append(ranges, RepoRange{Repo: repo, Range: spec, Version: version})
+		}
+	}
+
+	constraints := make([]*semver.Constraints, 0, len(ranges))
+	for _, r := range ranges {
+		c, err := semver.NewConstraint(r.Range)
+		if err != nil {
+			result.Conflicts = append(result.Conflicts, Conflict{
+				Package: name,
+				Reason:  fmt.Sprintf("%s declares %q, which is not a semver range", r.Repo, r.Range),
+				Ranges:  ranges,
+			})
+			return nil
+		}
+		constraints = append(constraints, c)
+	}
+
+	published, err := opts.Versions.ListVersions(ctx, name)
+	if errors.Is(err, ErrPackageNotFound) {
+		// such as a private package the registry does not have
+		log.Printf("Only considering the versions of %s in use: %v\n", name, err)
+		published, err = inventoryVersions{inv}.ListVersions(ctx, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list versions of %s: %w", name, err)
+	}
+	candidates := make([]*semver.Version, 0, len(published))
+	for _, version := range published {
+		if v, err := semver.NewVersion(version); err == nil && (opts.AllowPrerelease || channel(v) == opts.channel) {
+			candidates = append(candidates, v)
+		}
+	}
+	if highest {
+		sort.Sort(sort.Reverse(semver.Collection(candidates)))
+	} else {
+		sort.Sort(semver.Collection(candidates))
+	}
+
+	for _, candidate := range candidates {
+		if satisfiesAll(candidate, constraints) {
+			unifyTo(result, name, pkg.Versions, candidate.Original(), "satisfies every declared range")
+			return nil
+		}
+	}
+
+	result.Conflicts = append(result.Conflicts, Conflict{
+		Package: name,
+		Reason:  "no version satisfies every declared range",
+		Ranges:  ranges,
+	})
+
+	return nil
+}
+
+// unifyHighest moves every repo to the highest version in use.
+func unifyHighest(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	pkg := result.Inventory.Packages[name]
+	versions := semverVersions(pkg.Versions)
+	if len(versions) == 0 {
+		return nil
+	}
+
+	unifyTo(result, name, pkg.Versions, versions[len(versions)-1], "highest version in use")
+	return nil
+}
+
+// unifyMostUsed moves every repo to the version most repos already use. Ties
+// go to the higher version.
+func unifyMostUsed(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	pkg := result.Inventory.Packages[name]
+	versions := semverVersions(pkg.Versions)
+	if len(versions) == 0 {
+		return nil
+	}
+
+	target := versions[0]
+	for _, version := range versions[1:] {
+		if len(pkg.Versions[version]) >= len(pkg.Versions[target]) {
+			target = version
+		}
+	}
+
+	unifyTo(result, name, pkg.Versions, target, "used by most repos")
+	return nil
+}
+
+// unifyNewestWithinMajor moves every repo to the newest version in use with
+// the same major version, so no repo crosses a major version.
+func unifyNewestWithinMajor(ctx context.Context, result *UnifyResult, name string, opts UnifyOptions) error {
+	pkg := result.Inventory.Packages[name]
+
+	// the versions are in ascending order, so majors are too, and the merges
+	// do not depend on map order
+	var majors []uint64
+	groups := make(map[uint64]map[string][]string)
+	for _, version := range semverVersions(pkg.Versions) {
+		major := semver.MustParse(version).Major()
+		if groups[major] == nil {
+			groups[major] = make(map[string][]string)
+			majors = append(majors, major)
+		}
+		groups[major][version] = pkg.Versions[version]
+	}
+
+	for _, major := range majors {
+		versions := semverVersions(groups[major])
+		unifyTo(result, name, groups[major], versions[len(versions)-1], "newest version in use within its major version")
+	}
+	return nil
+}
+
+// semverVersions returns the keys of versions that are valid semver, in
+// ascending order.
+func semverVersions(versions map[string][]string) []string {
+	var valid []string
+	for _, version := range sortedVersions(versions) {
+		if _, err := semver.NewVersion(version); err == nil {
+			valid = append(valid, version)
+		}
+	}
+
+	return valid
+}
+
+// unifyTo moves every version of package name listed in versions to target.
+func unifyTo(result *UnifyResult, name string, versions map[string][]string, target string, why string) {
+	pkg := result.Inventory.Packages[name]
+	for _, version := range sortedVersions(versions) {
+		if version != target {
+			log.Printf("Moving %s@%s to %s (%s)\n", name, version, target, why)
+			mergeVersion(pkg, version, target)
+			result.Merges = append(result.Merges, Merge{name, version, target, why})
+		}
+	}
+}
+
+func satisfiesAll(v *semver.Version, constraints []*semver.Constraints) bool {
+	for _, c := range constraints {
+		if !c.Check(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mergeVersion moves the repos using version from of pkg to version to.
+func mergeVersion(pkg Package, from string, to string) {
+	repos := append(pkg.Versions[to], pkg.Versions[from]...)
+	sort.Strings(repos)
+	merged := repos[:0]
+	for i, repo := range repos {
+		if i == 0 || repo != repos[i-1] {
+			merged = append(merged, repo)
+		}
+	}
+	pkg.Versions[to] = merged
+	delete(pkg.Versions, from)
+}
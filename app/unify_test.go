@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// versionList is a VersionLister with fixed versions per package.
+type versionList map[string][]string
+
+func (l versionList) ListVersions(ctx context.Context, pkg string) ([]string, error) {
+	versions, exists := l[pkg]
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", pkg, ErrPackageNotFound)
+	}
+
+	return versions, nil
+}
+
+func TestUnifyStrategies(t *testing.T) {
+	// exact specs, so the ranges have no version in common
+	specs := map[string]string{"a": "1.2.0", "b": "1.2.5", "c": "1.4.0", "d": "1.4.0", "e": "2.0.0", "f": "2.1.0"}
+	unchanged := map[string][]string{"1.2.0": {"a"}, "1.2.5": {"b"}, "1.4.0": {"c", "d"}, "2.0.0": {"e"}, "2.1.0": {"f"}}
+
+	tests := []struct {
+		strategy string
+		want     map[string][]string
+		// conflict is whether the package is reported as a conflict.
+		conflict bool
+		// merges are the moves unify reports, in order.
+		merges []string
+	}{
+		{"", map[string][]string{"1.2.5": {"a", "b"}, "1.4.0": {"c", "d"}, "2.0.0": {"e"}, "2.1.0": {"f"}}, false,
+			[]string{"1.2.0 -> 1.2.5"}},
+		{StrategyPatch, map[string][]string{"1.2.5": {"a", "b"}, "1.4.0": {"c", "d"}, "2.0.0": {"e"}, "2.1.0": {"f"}}, false,
+			[]string{"1.2.0 -> 1.2.5"}},
+		{StrategyMinor, map[string][]string{"1.4.0": {"a", "b", "c", "d"}, "2.1.0": {"e", "f"}}, false,
+			[]string{"1.2.0 -> 1.4.0", "1.2.5 -> 1.4.0", "2.0.0 -> 2.1.0"}},
+		{StrategyHighest, map[string][]string{"2.1.0": {"a", "b", "c", "d", "e", "f"}}, false,
+			[]string{"1.2.0 -> 2.1.0", "1.2.5 -> 2.1.0", "1.4.0 -> 2.1.0", "2.0.0 -> 2.1.0"}},
+		{StrategyMostUsed, map[string][]string{"1.4.0": {"a", "b", "c", "d", "e", "f"}}, false,
+			[]string{"1.2.0 -> 1.4.0", "1.2.5 -> 1.4.0", "2.0.0 -> 1.4.0", "2.1.0 -> 1.4.0"}},
+		{StrategyNewestWithinMajor, map[string][]string{"1.4.0": {"a", "b", "c", "d"}, "2.1.0": {"e", "f"}}, false,
+			[]string{"1.2.0 -> 1.4.0", "1.2.5 -> 1.4.0", "2.0.0 -> 2.1.0"}},
+		{StrategyIntersect, unchanged, true, nil},
+		{StrategyLowestCommon, unchanged, true, nil},
+	}
+	for _, test := range tests {
+		inv := inventoryOf(t, manifestsWith(specs))
+		result, err := Unify(context.Background(), inv, UnifyOptions{Strategy: test.strategy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result.Inventory.Packages["dep"].Versions; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: unified to %v, want %v", test.strategy, got, test.want)
+		}
+		var merges []string
+		for _, merge := range result.Merges {
+			merges = append(merges, merge.From+" -> "+merge.To)
+		}
+		if !reflect.DeepEqual(merges, test.merges) {
+			t.Errorf("%q: merges %v, want %v", test.strategy, merges, test.merges)
+		}
+		if conflict := len(result.Conflicts) > 0; conflict != test.conflict {
+			t.Errorf("%q: conflicts %v", test.strategy, result.Conflicts)
+		}
+		if got := inv.Packages["dep"].Versions; !reflect.DeepEqual(got, unchanged) {
+			t.Errorf("%q: the inventory passed in changed to %v", test.strategy, got)
+		}
+	}
+}
+
+func TestUnifyIntersectPublished(t *testing.T) {
+	specs := map[string]string{"a": "^4.17.0", "b": "~4.17.5"}
+	published := versionList{"dep": {"4.17.0", "4.17.5", "4.17.21", "4.18.0", "5.0.0"}}
+
+	tests := []struct {
+		strategy string
+		versions VersionLister
+		want     string
+	}{
+		{StrategyIntersect, published, "4.17.21"},
+		{StrategyLowestCommon, published, "4.17.5"},
+		// without a registry, or one that does not have the package, only
+		// the versions in use are candidates
+		{StrategyIntersect, nil, "4.17.5"},
+		{StrategyIntersect, versionList{}, "4.17.5"},
+	}
+	for _, test := range tests {
+		inv := inventoryOf(t, manifestsWith(specs))
+		result, err := Unify(context.Background(), inv, UnifyOptions{Strategy: test.strategy, Versions: test.versions})
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions := result.Inventory.Packages["dep"].Versions
+		if len(versions) != 1 || len(versions[test.want]) != 2 {
+			t.Errorf("%s with %v: unified to %v, want %s", test.strategy, test.versions, versions, test.want)
+		}
+	}
+}
+
+func TestUsesPublishedVersions(t *testing.T) {
+	tests := []struct {
+		opts UnifyOptions
+		want bool
+	}{
+		{UnifyOptions{}, false},
+		{UnifyOptions{Strategy: StrategyHighest}, false},
+		{UnifyOptions{Strategy: StrategyIntersect}, true},
+		{UnifyOptions{Strategy: StrategyLowestCommon}, true},
+		{UnifyOptions{Policies: map[string]string{"react": StrategyIntersect}}, true},
+		{UnifyOptions{Policies: map[string]string{"*": StrategyMostUsed}}, false},
+	}
+	for _, test := range tests {
+		if got := test.opts.UsesPublishedVersions(); got != test.want {
+			t.Errorf("%+v: UsesPublishedVersions() = %v, want %v", test.opts, got, test.want)
+		}
+	}
+}
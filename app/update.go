@@ -0,0 +1,686 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/google/go-github/v44/github"
+)
+
+// RepoUpdate is a repo an update works on, and how it went.
+type RepoUpdate struct {
+	Repo string
+	Dir  string
+	// Skip is why the repo is not updated, if it is not.
+	Skip string
+	// Before is the manifest as it was before the update.
+	Before []byte
+	Result *UpdateResult
+	// Lockfile is the name of the repo's lockfile, if it has one, with its
+	// contents before and after the update.
+	Lockfile   string
+	LockBefore []byte
+	LockAfter  []byte
+	// PullRequest is the pull request proposing the update, for repos
+	// updated on GitHub.
+	PullRequest *PullRequestResult
+	// Branch is the branch the update was committed on, with Git, and
+	// Pushed whether it was pushed.
+	Branch string
+	Pushed bool
+	Err    error
+}
+
+// RepoUpdateOptions is how UpdateRepos and EditRepos treat the repos they
+// change.
+type RepoUpdateOptions struct {
+	// DryRun writes nothing.
+	DryRun   bool
+	Lockfile LockfileConfig
+	// Update and Exclusions decide how manifests are updated, as for
+	// UpdateManifest.
+	Update     UpdateConfig
+	Exclusions Exclusions
+	// Npmrc holds the user's npm settings; those of each repo's .npmrc are
+	// read over them.
+	Npmrc Npmrc
+	// Client is set to update repos on GitHub rather than in checkouts,
+	// proposing the changes as Remote says.
+	Client *github.Client
+	Remote RemoteConfig
+	// Git is set to commit the changes in each checkout on a branch.
+	Git *GitConfig
+	// Sections is set to also move misplaced dependencies between sections.
+	Sections *SectionOptions
+	// Jobs is how many repos are changed at once.
+	Jobs int
+}
+
+// SelectRepos returns the repos of inv matching patterns, or all of them
+// when there are none, with the directory each is checked out in: under
+// root when it is set, otherwise where it was parsed from. Repos without a
+// directory are skipped.
+func SelectRepos(inv *Inventory, root string, patterns []string) ([]*RepoUpdate, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid repo pattern %q: %w", pattern, err)
+		}
+	}
+
+	var targets []*RepoUpdate
+	for _, repo := range sortedKeys(inv.Repos) {
+		if !matchesAny(patterns, repo) {
+			continue
+		}
+
+		target := &RepoUpdate{Repo: repo}
+		info := inv.Repos[repo]
+		switch {
+		case root != "":
+			target.Dir = filepath.Join(root, filepath.FromSlash(repo))
+			if !IsValidDir(target.Dir) {
+				// GitHub repos are owner/name, but usually cloned as name
+				target.Dir = filepath.Join(root, path.Base(repo))
+			}
+		case info.Source == SourceDir:
+			target.Dir = info.Location
+		default:
+			target.Skip = "not parsed from a directory; pass --dir"
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// SelectRemoteRepos returns the repos of inv read from GitHub that match
+// patterns, or all of them when there are none. Other repos are skipped.
+func SelectRemoteRepos(inv *Inventory, patterns []string) ([]*RepoUpdate, error) {
+	targets, err := SelectRepos(inv, "", patterns)
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		target.Dir, target.Skip = "", ""
+		if inv.Repos[target.Repo].Source != SourceGithub {
+			target.Skip = "not read from GitHub"
+		}
+	}
+
+	return targets, nil
+}
+
+// UpdateRepos updates each of targets that is not skipped to the versions
+// inv has for it, at most opts.Jobs at a time, setting its outcome. Repos
+// are updated on GitHub when opts.Client is set, otherwise in their
+// checkouts, as UpdateRepo does.
+func UpdateRepos(ctx context.Context, inv *Inventory, targets []*RepoUpdate, opts RepoUpdateOptions) {
+	forEachRepo(targets, opts.Jobs, func(target *RepoUpdate) error {
+		if opts.Client != nil {
+			return updateRemoteRepo(ctx, inv, target, opts)
+		}
+		return UpdateRepo(ctx, inv, target, opts)
+	})
+}
+
+// UpdateRepo updates the package.json in target.Dir, the checkout of
+// target.Repo, to the versions inv has for it, and then its lockfile. When
+// the lockfile cannot be regenerated, both files are put back as they were.
+// With opts.DryRun nothing is written, and the new lockfile is worked out in
+// a temporary directory.
+func UpdateRepo(ctx context.Context, inv *Inventory, target *RepoUpdate, opts RepoUpdateOptions) error {
+	return editRepo(ctx, target, opts, func(npmrc Npmrc) error {
+		err := updateRepoManifest(ctx, inv, target, npmrc, opts)
+		if err != nil || opts.Sections == nil {
+			return err
+		}
+		return fixRepoSections(inv, target.Result, *opts.Sections, target.Dir)
+	})
+}
+
+// EditRepos changes the package.json of each of targets that is not skipped
+// with edit, at most opts.Jobs at a time, and regenerates its lockfile, as
+// UpdateRepo does. edit returns the result and why a repo without changes
+// is skipped. Unless it is a dry run, the changes are applied to inv, and
+// EditRepos reports whether there were any.
+func EditRepos(ctx context.Context, inv *Inventory, targets []*RepoUpdate, opts RepoUpdateOptions, edit func(target *RepoUpdate) (*UpdateResult, string, error)) bool {
+	forEachRepo(targets, opts.Jobs, func(target *RepoUpdate) error {
+		return editRepo(ctx, target, opts, func(npmrc Npmrc) error {
+			result, skip, err := edit(target)
+			if err != nil {
+				return err
+			}
+			target.Result = result
+			if len(result.Changes) == 0 {
+				target.Skip = skip
+			}
+			return nil
+		})
+	})
+	if opts.DryRun {
+		return false
+	}
+
+	changed := false
+	for _, target := range targets {
+		if target.Err == nil && target.Result != nil && len(target.Result.Changes) > 0 {
+			inv.ApplyChanges(target.Repo, target.Result.Changes)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// FixRepoSections moves the misplaced dependencies of target.Before to the
+// section opts decides, scanning the imports of the checkout in target.Dir
+// when opts uses them.
+func FixRepoSections(inv *Inventory, target *RepoUpdate, opts SectionOptions) (*UpdateResult, error) {
+	result := &UpdateResult{Repo: target.Repo, Manifest: target.Before}
+	err := fixRepoSections(inv, result, opts, target.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// UpdateFailures returns an error if any of targets failed.
+func UpdateFailures(targets []*RepoUpdate) error {
+	failed := 0
+	for _, target := range targets {
+		if target.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repos failed to update", failed, len(targets))
+	}
+
+	return nil
+}
+
+// forEachRepo runs fn on each of targets that is not skipped, at most jobs
+// at a time, setting its Err.
+func forEachRepo(targets []*RepoUpdate, jobs int, fn func(target *RepoUpdate) error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		if target.Skip != "" {
+			continue
+		}
+		wg.Add(1)
+		go func(target *RepoUpdate) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			target.Err = fn(target)
+		}(target)
+	}
+	wg.Wait()
+}
+
+// editRepo changes the package.json in target.Dir with edit, which sets
+// target.Result from target.Before, and then regenerates the lockfile, as
+// described for UpdateRepo.
+func editRepo(ctx context.Context, target *RepoUpdate, opts RepoUpdateOptions, edit func(npmrc Npmrc) error) error {
+	manifestPath := filepath.Join(target.Dir, "package.json")
+	info, err := os.Stat(manifestPath)
+	if err != nil {
+		return err
+	}
+	checkout := GitCheckout{Dir: target.Dir}
+	if opts.Git != nil {
+		err = checkout.CheckClean(ctx)
+		if err == nil {
+			target.Before, err = checkout.Show(ctx, opts.Git.BaseRevision(), "package.json")
+		}
+	} else {
+		target.Before, err = os.ReadFile(manifestPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	npmrc, err := ReadNpmrc(filepath.Join(target.Dir, ".npmrc"))
+	if err != nil {
+		return err
+	}
+	err = edit(opts.Npmrc.merge(npmrc))
+	if err != nil || len(target.Result.Changes) == 0 {
+		return err
+	}
+
+	var previous string
+	if opts.Git != nil && !opts.DryRun {
+		previous, err = startBranch(ctx, checkout, target, *opts.Git)
+		if err != nil {
+			return err
+		}
+	}
+
+	pm, hasLockfile := DetectPackageManager(target.Dir)
+	if hasLockfile && !opts.Lockfile.Skip {
+		target.Lockfile = pm.Lockfile
+		target.LockBefore, err = os.ReadFile(filepath.Join(target.Dir, pm.Lockfile))
+		if err != nil {
+			return err
+		}
+	}
+	if opts.DryRun {
+		if target.Lockfile != "" {
+			previewLockfile(ctx, target, pm, opts.Lockfile.Registry)
+		}
+		return nil
+	}
+
+	err = writeUpdate(ctx, target, pm, info.Mode().Perm(), opts.Lockfile)
+	if opts.Git == nil {
+		return err
+	}
+	if err == nil {
+		err = commitUpdate(ctx, checkout, target, *opts.Git, info.Mode().Perm())
+	}
+	if err != nil {
+		switchErr := checkout.Switch(ctx, previous)
+		if switchErr != nil {
+			return fmt.Errorf("%w; switching back to %s also failed: %v", err, previous, switchErr)
+		}
+		return err
+	}
+
+	return pushUpdate(ctx, checkout, target, *opts.Git)
+}
+
+// writeUpdate writes the updated manifest of target and regenerates its
+// lockfile. When the lockfile cannot be regenerated, both files are put back
+// as they were.
+func writeUpdate(ctx context.Context, target *RepoUpdate, pm PackageManager, perm os.FileMode, config LockfileConfig) error {
+	manifestPath := filepath.Join(target.Dir, "package.json")
+	err := WriteFileAtomic(manifestPath, target.Result.Manifest, perm)
+	if err != nil || target.Lockfile == "" {
+		return err
+	}
+
+	lockPath := filepath.Join(target.Dir, target.Lockfile)
+	lockInfo, err := os.Stat(lockPath)
+	if err == nil {
+		err = RegenerateLockfile(ctx, target.Dir, pm, config.Registry)
+	}
+	if err == nil {
+		target.LockAfter, err = os.ReadFile(lockPath)
+	}
+	if err != nil {
+		rollbackErr := WriteFileAtomic(manifestPath, target.Before, perm)
+		if rollbackErr == nil && lockInfo != nil {
+			rollbackErr = WriteFileAtomic(lockPath, target.LockBefore, lockInfo.Mode().Perm())
+		}
+		if rollbackErr != nil {
+			return fmt.Errorf("%w; rolling back also failed: %v", err, rollbackErr)
+		}
+		return fmt.Errorf("%w; package.json and %s rolled back", err, target.Lockfile)
+	}
+
+	return nil
+}
+
+// startBranch checks out the branch config names for the update of target,
+// created from its base, and returns the branch or commit checked out
+// before.
+func startBranch(ctx context.Context, checkout GitCheckout, target *RepoUpdate, config GitConfig) (string, error) {
+	branch, _, err := config.Render(TemplateData{Repo: target.Repo, Changes: target.Result.Changes})
+	if err != nil {
+		return "", err
+	}
+	previous, err := checkout.CurrentBranch(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = checkout.Checkout(ctx, branch, config.BaseRevision())
+	if err != nil {
+		return "", err
+	}
+	target.Branch = branch
+
+	return previous, nil
+}
+
+// commitUpdate commits the files the update changed in target on the
+// branch checked out by startBranch. When the commit fails, the files are
+// put back as they were on the branch, with perm, and unstaged.
+func commitUpdate(ctx context.Context, checkout GitCheckout, target *RepoUpdate, config GitConfig, perm os.FileMode) error {
+	files := []string{"package.json"}
+	if target.LockAfter != nil && !bytes.Equal(target.LockAfter, target.LockBefore) {
+		files = append(files, target.Lockfile)
+	}
+	_, message, err := config.Render(TemplateData{Repo: target.Repo, Changes: target.Result.Changes, Files: files})
+	if err == nil {
+		err = checkout.Commit(ctx, message, files...)
+	}
+	if err == nil {
+		return nil
+	}
+
+	rollbackErr := WriteFileAtomic(filepath.Join(target.Dir, "package.json"), target.Before, perm)
+	if rollbackErr == nil && len(files) > 1 {
+		lockPath := filepath.Join(target.Dir, target.Lockfile)
+		var lockInfo os.FileInfo
+		lockInfo, rollbackErr = os.Stat(lockPath)
+		if rollbackErr == nil {
+			rollbackErr = WriteFileAtomic(lockPath, target.LockBefore, lockInfo.Mode().Perm())
+		}
+	}
+	if rollbackErr == nil {
+		rollbackErr = checkout.Unstage(ctx, files...)
+	}
+	if rollbackErr != nil {
+		return fmt.Errorf("%w; rolling back also failed: %v", err, rollbackErr)
+	}
+
+	return err
+}
+
+// pushUpdate pushes the branch target was committed on, if config says so.
+func pushUpdate(ctx context.Context, checkout GitCheckout, target *RepoUpdate, config GitConfig) error {
+	if !config.Push {
+		return nil
+	}
+	err := checkout.Push(ctx, config.RemoteName(), target.Branch)
+	if err != nil {
+		return fmt.Errorf("%w; committed on %s but not pushed", err, target.Branch)
+	}
+	target.Pushed = true
+
+	return nil
+}
+
+// updateRepoManifest sets target.Result to target.Before updated to the
+// versions inv has for target.Repo, with the options opts and npmrc set.
+func updateRepoManifest(ctx context.Context, inv *Inventory, target *RepoUpdate, npmrc Npmrc, opts RepoUpdateOptions) error {
+	update := opts.Update.UpdateOptionsFor(target.Repo)
+	update.Exclusions = opts.Exclusions
+	update.SavePrefix = npmrc.SavePrefix()
+
+	var err error
+	target.Result, err = UpdateManifest(ctx, inv, target.Repo, target.Before, update)
+	if err != nil {
+		return err
+	}
+	for _, exclusion := range target.Result.Excluded {
+		log.Printf("Leaving %s in %s alone: %s\n", exclusion.Package, target.Repo, exclusion.Reason)
+	}
+
+	return nil
+}
+
+// fixRepoSections moves the misplaced dependencies of result.Manifest to
+// the section opts decides, adding the moves to its changes. The imports of
+// the checkout in dir are scanned when opts uses them; without a checkout
+// the imports rule is skipped.
+func fixRepoSections(inv *Inventory, result *UpdateResult, opts SectionOptions, dir string) error {
+	if opts.UsesImports() && dir != "" {
+		imports, err := ScanImports(dir)
+		if err != nil {
+			return err
+		}
+		opts.Imports = imports
+	}
+
+	moved, err := FixSections(inv, result.Repo, result.Manifest, opts)
+	if err != nil {
+		return err
+	}
+	result.Manifest = moved.Manifest
+	result.Changes = append(result.Changes, moved.Changes...)
+
+	return nil
+}
+
+// updateRemoteRepo updates the package.json of target.Repo on GitHub, and
+// its lockfile, worked out in a temporary directory, and proposes them in a
+// pull request. Nothing is pushed when the lockfile cannot be regenerated.
+// With opts.DryRun nothing is pushed at all.
+func updateRemoteRepo(ctx context.Context, inv *Inventory, target *RepoUpdate, opts RepoUpdateOptions) error {
+	repo, err := OpenGitHubRepo(ctx, opts.Client, target.Repo)
+	if err != nil {
+		return err
+	}
+	target.Dir = "https://github.com/" + repo.FullName()
+	target.Before, err = repo.ReadFile(ctx, "package.json")
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]byte)
+	for _, name := range ConfigFiles {
+		if !repo.HasFile(name) {
+			continue
+		}
+		files[name], err = repo.ReadFile(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+	err = updateRepoManifest(ctx, inv, target, opts.Npmrc.merge(ParseNpmrc(files[".npmrc"])), opts)
+	if err == nil && opts.Sections != nil {
+		// there is no checkout to scan imports in
+		err = fixRepoSections(inv, target.Result, *opts.Sections, "")
+	}
+	if err != nil || len(target.Result.Changes) == 0 {
+		return err
+	}
+
+	pm, hasLockfile := repo.PackageManager(ctx)
+	if hasLockfile && !opts.Lockfile.Skip {
+		target.Lockfile = pm.Lockfile
+		target.LockBefore, err = repo.ReadFile(ctx, pm.Lockfile)
+		if err != nil {
+			return err
+		}
+		files["package.json"] = target.Result.Manifest
+		files[pm.Lockfile] = target.LockBefore
+		target.LockAfter, err = RegenerateLockfileFiles(ctx, files, pm, opts.Lockfile.Registry)
+		if err != nil && opts.DryRun {
+			log.Printf("Cannot preview %s of %s: %v\n", target.Lockfile, target.Repo, err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w; nothing pushed", err)
+		}
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	// only files whose contents differ make a commit, so a rerun against an
+	// up to date repo opens no pull request
+	changed := make(map[string][]byte)
+	if !bytes.Equal(target.Result.Manifest, target.Before) {
+		changed["package.json"] = target.Result.Manifest
+	}
+	if target.LockAfter != nil && !bytes.Equal(target.LockAfter, target.LockBefore) {
+		changed[target.Lockfile] = target.LockAfter
+	}
+	if len(changed) == 0 {
+		target.Result.Changes = nil
+		return nil
+	}
+	target.PullRequest, err = repo.ProposeChanges(ctx, changed, opts.Remote, TemplateData{
+		Repo:    repo.FullName(),
+		Changes: target.Result.Changes,
+	})
+
+	return err
+}
+
+// previewLockfile regenerates the lockfile of target in a temporary copy of
+// the files the package manager reads, for a dry run. A lockfile that cannot
+// be worked out is only a warning, as nothing is being changed.
+func previewLockfile(ctx context.Context, target *RepoUpdate, pm PackageManager, registry string) {
+	files := map[string][]byte{"package.json": target.Result.Manifest, target.Lockfile: target.LockBefore}
+	for _, name := range ConfigFiles {
+		if data, err := os.ReadFile(filepath.Join(target.Dir, name)); err == nil {
+			files[name] = data
+		}
+	}
+
+	var err error
+	target.LockAfter, err = RegenerateLockfileFiles(ctx, files, pm, registry)
+	if err != nil {
+		log.Printf("Cannot preview %s of %s: %v\n", target.Lockfile, target.Repo, err)
+	}
+}
+
+// WriteUpdatesJSON writes the outcome of each of targets as indented JSON.
+func WriteUpdatesJSON(w io.Writer, targets []*RepoUpdate) error {
+	type repoJSON struct {
+		Repo    string             `json:"repo"`
+		Dir     string             `json:"dir"`
+		Status  string             `json:"status"`
+		Error   string             `json:"error,omitempty"`
+		Changes []DependencyChange `json:"changes"`
+		// PullRequest is the URL of the pull request, for repos updated on
+		// GitHub.
+		PullRequest string `json:"pullRequest,omitempty"`
+		Branch      string `json:"branch,omitempty"`
+		Pushed      bool   `json:"pushed,omitempty"`
+	}
+
+	repos := []repoJSON{}
+	for _, target := range targets {
+		repo := repoJSON{Repo: target.Repo, Dir: target.Dir, Changes: []DependencyChange{}}
+		switch {
+		case target.Skip != "":
+			repo.Status, repo.Error = "skipped", target.Skip
+		case target.Err != nil:
+			repo.Status, repo.Error = "failed", target.Err.Error()
+		case len(target.Result.Changes) == 0:
+			repo.Status = "unchanged"
+		default:
+			repo.Status = "changed"
+			repo.Changes = target.Result.Changes
+			if target.PullRequest != nil {
+				repo.PullRequest = target.PullRequest.URL
+			}
+			repo.Branch, repo.Pushed = target.Branch, target.Pushed
+		}
+		repos = append(repos, repo)
+	}
+
+	data, err := json.MarshalIndent(repos, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// WriteUpdatesText writes what the update changed in each of targets, as
+// WriteUpdate does, followed by a table of how each repo went. Repos that
+// failed are only in the table, as their changes were rolled back or never
+// made.
+func WriteUpdatesText(w io.Writer, targets []*RepoUpdate, dryRun bool) error {
+	for _, target := range targets {
+		if target.Result == nil || target.Err != nil {
+			continue
+		}
+		err := WriteUpdate(w, target, dryRun)
+		if err != nil {
+			return err
+		}
+	}
+
+	updated := "updated"
+	if dryRun {
+		updated = "would update"
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nREPO\tSTATUS\tCHANGES\tDETAIL")
+	for _, target := range targets {
+		switch {
+		case target.Skip != "":
+			fmt.Fprintf(tw, "%s\tskipped\t-\t%s\n", target.Repo, target.Skip)
+		case target.Err != nil:
+			fmt.Fprintf(tw, "%s\tfailed\t-\t%s\n", target.Repo, strings.Join(strings.Fields(target.Err.Error()), " "))
+		case len(target.Result.Changes) == 0:
+			fmt.Fprintf(tw, "%s\tunchanged\t0\t%s\n", target.Repo, target.Dir)
+		case target.PullRequest != nil && target.PullRequest.Unchanged:
+			fmt.Fprintf(tw, "%s\tPR up to date\t%d\t%s\n", target.Repo, len(target.Result.Changes), target.PullRequest.URL)
+		case target.PullRequest != nil && target.PullRequest.Created:
+			fmt.Fprintf(tw, "%s\topened PR\t%d\t%s\n", target.Repo, len(target.Result.Changes), target.PullRequest.URL)
+		case target.PullRequest != nil:
+			fmt.Fprintf(tw, "%s\tupdated PR\t%d\t%s\n", target.Repo, len(target.Result.Changes), target.PullRequest.URL)
+		case target.Pushed:
+			fmt.Fprintf(tw, "%s\tpushed\t%d\t%s on %s\n", target.Repo, len(target.Result.Changes), target.Dir, target.Branch)
+		case target.Branch != "":
+			fmt.Fprintf(tw, "%s\tcommitted\t%d\t%s on %s\n", target.Repo, len(target.Result.Changes), target.Dir, target.Branch)
+		default:
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", target.Repo, updated, len(target.Result.Changes), target.Dir)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// WriteUpdate writes what the update changed in target, as WriteChanges
+// does, or with dryRun the diff of what it would change.
+func WriteUpdate(w io.Writer, target *RepoUpdate, dryRun bool) error {
+	if !dryRun {
+		return WriteChanges(w, target.Result)
+	}
+
+	name := filepath.ToSlash(filepath.Join(target.Repo, "package.json"))
+	_, err := io.WriteString(w, UnifiedDiff("a/"+name, "b/"+name, target.Before, target.Result.Manifest))
+	if err != nil || target.LockAfter == nil {
+		return err
+	}
+	name = filepath.ToSlash(filepath.Join(target.Repo, target.Lockfile))
+	_, err = io.WriteString(w, UnifiedDiff("a/"+name, "b/"+name, target.LockBefore, target.LockAfter))
+
+	return err
+}
+
+// WriteChanges lists the dependencies update, add or remove changed in a
+// repo, marking those whose range operator changed or that were moved.
+func WriteChanges(w io.Writer, result *UpdateResult) error {
+	if len(result.Changes) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s:\n", result.Repo)
+	for _, change := range result.Changes {
+		note := ""
+		switch {
+		case strings.HasPrefix(change.Reason, "moved"):
+			note = "(" + change.Reason + ")"
+		case change.OperatorChanged:
+			note = "(operator changed)"
+		}
+		spec := change.From + " -> " + change.To
+		switch {
+		case change.From == change.To:
+			spec = change.To
+		case change.From == "":
+			spec = "added " + change.To
+		case change.To == "":
+			spec = "removed " + change.From
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", change.Package, change.Section, spec, note)
+	}
+
+	return tw.Flush()
+}
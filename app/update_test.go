@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkouts writes a checkout with a package.json declaring dep with spec
+// for each repo in specs, and returns their parent directory.
+func checkouts(t *testing.T, specs map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for repo, spec := range specs {
+		dir := filepath.Join(root, repo)
+		err := os.Mkdir(dir, 0777)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, "package.json"), manifestWith(spec), 0666)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestSelectRepos(t *testing.T) {
+	root := checkouts(t, map[string]string{"api": "^1.0.0", "payments-api": "^1.0.0", "payments-web": "^1.0.0"})
+	inv, err := BuildInventory(context.Background(), NewDirSource(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{nil, []string{"api", "payments-api", "payments-web"}},
+		{[]string{"payments-*"}, []string{"payments-api", "payments-web"}},
+		{[]string{"api", "*-web"}, []string{"api", "payments-web"}},
+		{[]string{"none"}, nil},
+	}
+	for _, test := range tests {
+		targets, err := SelectRepos(inv, "", test.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, target := range targets {
+			got = append(got, target.Repo)
+			if want := filepath.Join(root, target.Repo); target.Dir != want || target.Skip != "" {
+				t.Errorf("%s: dir %q, skip %q, want dir %q", target.Repo, target.Dir, target.Skip, want)
+			}
+		}
+		if !equalStrings(got, test.want) {
+			t.Errorf("SelectRepos(%q) = %v, want %v", test.patterns, got, test.want)
+		}
+	}
+
+	if _, err := SelectRepos(inv, "", []string{"["}); err == nil {
+		t.Error("SelectRepos accepted an invalid pattern")
+	}
+	targets, err := SelectRemoteRepos(inv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range targets {
+		if target.Skip == "" {
+			t.Errorf("SelectRemoteRepos did not skip %s, parsed from a directory", target.Repo)
+		}
+	}
+}
+
+func TestUpdateRepos(t *testing.T) {
+	root := checkouts(t, map[string]string{"a": "^4.17.1", "b": "^4.17.21", "c": "1.x"})
+	inv, err := BuildInventory(context.Background(), NewDirSource(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moveTo(inv, "dep", "4.17.21")
+
+	for _, dryRun := range []bool{true, false} {
+		targets, err := SelectRepos(inv, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		UpdateRepos(context.Background(), inv, targets, RepoUpdateOptions{DryRun: dryRun, Jobs: 2})
+		if err := UpdateFailures(targets); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{"a": "^4.17.21", "b": "^4.17.21", "c": "1.x"}
+		if dryRun {
+			want["a"] = "^4.17.1"
+		}
+		for _, target := range targets {
+			if changed := len(target.Result.Changes) > 0; changed != (target.Repo == "a") {
+				t.Errorf("dry run %v: %s changes %v", dryRun, target.Repo, target.Result.Changes)
+			}
+			data, err := os.ReadFile(filepath.Join(target.Dir, "package.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != string(manifestWith(want[target.Repo])) {
+				t.Errorf("dry run %v: %s has\n%s", dryRun, target.Repo, data)
+			}
+		}
+	}
+}
+
+func TestWriteUpdatesText(t *testing.T) {
+	changes := []DependencyChange{{Package: "dep", Section: "dependencies", From: "^4.17.1", To: "^4.17.21"}}
+	targets := []*RepoUpdate{
+		{Repo: "a", Dir: "/src/a", Result: &UpdateResult{Repo: "a", Changes: changes}},
+		{Repo: "b", Dir: "/src/b", Result: &UpdateResult{Repo: "b", Changes: changes},
+			Err: errors.New("npm install failed; package.json and package-lock.json rolled back")},
+		{Repo: "c", Skip: "not parsed from a directory; pass --dir"},
+	}
+
+	var out strings.Builder
+	if err := WriteUpdatesText(&out, targets, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `a:
+  dep  dependencies  ^4.17.1 -> ^4.17.21  
+
+REPO  STATUS   CHANGES  DETAIL
+a     updated  1        /src/a
+b     failed   -        npm install failed; package.json and package-lock.json rolled back
+c     skipped  -        not parsed from a directory; pass --dir
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,259 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultConfigFile is read from the current directory when no config
+	// file is given explicitly.
+	DefaultConfigFile = ".pacman.json"
+	lockFileName      = "pacman.lock"
+)
+
+// ErrNoInventory is returned when a workspace has no state file yet.
+var ErrNoInventory = errors.New(stateFileName + " file missing. Run parse to create it")
+
+// Config holds the settings read from the pacman config file.
+type Config struct {
+	// Workdir is where state and outputs are kept. A relative path is
+	// resolved against the directory of the config file.
+	Workdir    string           `json:"workdir"`
+	Backups    BackupConfig     `json:"backups"`
+	Aggregate  AggregateConfig  `json:"aggregate"`
+	Unify      UnifyConfig      `json:"unify"`
+	Update     UpdateConfig     `json:"update"`
+	Lockfile   LockfileConfig   `json:"lockfile"`
+	Remote     RemoteConfig     `json:"remote"`
+	Git        GitConfig        `json:"git"`
+	Sections   SectionsConfig   `json:"sections"`
+	Registry   RegistryConfig   `json:"registry"`
+	Deprecated DeprecatedConfig `json:"deprecated"`
+	// Exclusions are read from the top-level "holds" and "ignore" keys.
+	Exclusions
+}
+
+// LoadConfig reads the config file at path. A missing file is only an error
+// when it was asked for explicitly.
+func LoadConfig(path string, explicit bool) (*Config, error) {
+	config := &Config{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if config.Workdir != "" && !filepath.IsAbs(config.Workdir) {
+		config.Workdir = filepath.Join(filepath.Dir(path), config.Workdir)
+	}
+
+	return config, nil
+}
+
+// Workspace is the directory pacman keeps its state and outputs in: the
+// state file, the aggregate package.json, packages_list.json, aliases.json,
+// snapshots and backups. The library functions never touch the file system
+// themselves; callers that want pacman's files use a Workspace.
+type Workspace struct {
+	Dir    string
+	Config Config
+}
+
+// OpenWorkspace returns the workspace in dir, creating the directory when it
+// does not exist yet.
+func OpenWorkspace(dir string, config *Config) (*Workspace, error) {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workdir %s: %w", dir, err)
+	}
+
+	w := &Workspace{Dir: dir}
+	if config != nil {
+		w.Config = *config
+	}
+	if w.Config.Backups.Keep == 0 {
+		w.Config.Backups.Keep = defaultBackupKeep
+	}
+	if w.Config.Backups.MaxAge != "" {
+		if _, err := parseAge(w.Config.Backups.MaxAge); err != nil {
+			return nil, fmt.Errorf("invalid backups.maxAge %q: %w", w.Config.Backups.MaxAge, err)
+		}
+	}
+	if w.Config.Registry.CacheTTL != "" {
+		if _, err := parseAge(w.Config.Registry.CacheTTL); err != nil {
+			return nil, fmt.Errorf("invalid registry.cacheTTL %q: %w", w.Config.Registry.CacheTTL, err)
+		}
+	}
+
+	return w, nil
+}
+
+func (w *Workspace) path(name string) string {
+	return filepath.Join(w.Dir, name)
+}
+
+// Lock takes the workspace lock, so that concurrent runs cannot clobber each
+// other's state. When another run holds the lock, Lock retries until wait
+// has passed and then gives up. Locks left behind by runs that are no longer
+// alive are removed. The returned function releases the lock.
+func (w *Workspace) Lock(wait time.Duration) (func(), error) {
+	path := w.path(lockFileName)
+	deadline := time.Now().Add(wait)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock %s: %w", path, err)
+		}
+
+		pid := lockHolder(path)
+		if pid > 0 && !processAlive(pid) {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			if pid > 0 {
+				return nil, fmt.Errorf("%s is locked by another pacman run (pid %d)", w.Dir, pid)
+			}
+			return nil, errors.New(w.Dir + " is locked by another pacman run")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// lockHolder returns the pid recorded in the lock file, or 0 if it cannot be
+// read, for example because the holder is still writing it.
+func lockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+// LoadInventory reads the state file. When there is none but a packages.gob
+// left behind by older versions of pacman, it is converted to a state file
+// and kept as packages.gob.migrated, so the conversion only happens once.
+func (w *Workspace) LoadInventory() (*Inventory, error) {
+	data, err := os.ReadFile(w.path(stateFileName))
+	if os.IsNotExist(err) {
+		return w.migrateLegacyInventory()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", stateFileName, err)
+	}
+
+	return DecodeInventory(data)
+}
+
+func (w *Workspace) migrateLegacyInventory() (*Inventory, error) {
+	legacyPath := w.path(legacyStateFileName)
+	info, err := os.Stat(legacyPath)
+	if err != nil {
+		return nil, ErrNoInventory
+	}
+	data, err := os.ReadFile(legacyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", legacyStateFileName, err)
+	}
+
+	inv, err := DecodeLegacyInventory(data, info.ModTime())
+	if err != nil {
+		return nil, err
+	}
+	err = w.writeInventory(inv)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(legacyPath, legacyPath+".migrated")
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename %s: %w", legacyStateFileName, err)
+	}
+	log.Printf("Migrated %s to %s\n", legacyStateFileName, stateFileName)
+
+	return inv, nil
+}
+
+func (w *Workspace) writeInventory(inv *Inventory) error {
+	data, err := EncodeInventory(inv)
+	if err != nil {
+		return err
+	}
+	err = WriteFileAtomic(w.path(stateFileName), data, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", stateFileName, err)
+	}
+
+	return nil
+}
+
+// SaveInventory backs up the current state files and then writes inv to the
+// state file, together with the aggregate package.json, packages_list.json
+// and aliases.json derived from it.
+func (w *Workspace) SaveInventory(inv *Inventory) error {
+	err := w.Backup()
+	if err != nil {
+		return err
+	}
+	err = w.writeInventory(inv)
+	if err != nil {
+		return err
+	}
+
+	err = WriteFileAtomic(w.path("packages_list.json"), RenderPackagesList(inv), 0666)
+	if err != nil {
+		return fmt.Errorf("failed to create packages_list.json: %w", err)
+	}
+
+	aggregate, aliases := RenderAggregate(inv, w.Config.Aggregate)
+	err = WriteFileAtomic(w.path("package.json"), aggregate, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to create package.json: %w", err)
+	}
+	err = WriteFileAtomic(w.path(aliasMapFileName), RenderAliasMap(aliases), 0666)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", aliasMapFileName, err)
+	}
+
+	for _, problem := range ValidateManifest(aggregate) {
+		log.Println("Warning: package.json will not install:", problem)
+	}
+
+	return nil
+}
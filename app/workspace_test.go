@@ -0,0 +1,56 @@
+package app
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	// the pid of a process that has exited
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name string
+		// locked is whether another run left a lock file, holding holder.
+		locked bool
+		holder string
+		taken  bool
+	}{
+		{"free", false, "", true},
+		{"held", true, strconv.Itoa(os.Getpid()), false},
+		{"stale", true, strconv.Itoa(exited.Process.Pid), true},
+		{"being written", true, "", false},
+	}
+
+	for _, test := range tests {
+		w, err := OpenWorkspace(t.TempDir(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.locked {
+			if err := os.WriteFile(w.path(lockFileName), []byte(test.holder), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		unlock, err := w.Lock(0)
+		if taken := err == nil; taken != test.taken {
+			t.Errorf("%s: Lock() = %v, want taken %v", test.name, err, test.taken)
+		}
+		if err != nil {
+			continue
+		}
+		if _, err := w.Lock(0); err == nil {
+			t.Errorf("%s: the lock was taken twice", test.name)
+		}
+		unlock()
+		if _, err := os.Stat(w.path(lockFileName)); !os.IsNotExist(err) {
+			t.Errorf("%s: the lock is left behind after unlocking", test.name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// addCmd represents the add command
+var addCmd = &cobra.Command{
+	Use:   "add <package>@<spec> --select <pattern>",
+	Short: "Adds a dependency to the package.json of the selected repos",
+	Long: `Adds a dependency to the package.json of every repo matching --select,
+in dependencies or, with --dev, in devDependencies. A repo that already has
+the package gets the new spec; one that has it in the other section has it
+moved, as npm install does. The lockfile of each changed repo is
+regenerated, as with update, and the inventory is refreshed.
+
+Repos parsed from a directory are changed where they were parsed; --dir
+gives a directory holding checkouts of the repos instead. For example:
+
+pacman add lodash@^4.17.21 --select '*'
+pacman add @types/node@^18.0.0 --dev --select 'payments-*' --dry-run`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := checkEditFlags(cmd); err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return errors.New("requires a <package>@<spec> argument")
+		}
+		name, _, ok := parsePackageSpec(args[0])
+		if !ok {
+			return fmt.Errorf("invalid package %q: expected <package>@<spec>", args[0])
+		}
+		return app.ValidatePackageName(name)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, spec, _ := parsePackageSpec(args[0])
+		dev := cmd.Flag("dev").Changed
+
+		return editRepos(cmd, func(inv *app.Inventory, target *app.RepoUpdate) (*app.UpdateResult, string, error) {
+			result, err := app.AddDependency(target.Repo, target.Before, name, spec, dev)
+			return result, "already has " + name + "@" + spec, err
+		})
+	},
+}
+
+// parsePackageSpec splits name@spec, where name may be scoped, such as
+// @types/node@^18.0.0, at the first @ after the start of the name, so specs
+// such as npm:lodash@^4.17.21 are kept whole.
+func parsePackageSpec(arg string) (string, string, bool) {
+	i := strings.IndexByte(arg, '@')
+	if i == 0 {
+		i = strings.IndexByte(arg[1:], '@') + 1
+	}
+	if i <= 0 || i == len(arg)-1 {
+		return "", "", false
+	}
+
+	return arg[:i], arg[i+1:], true
+}
+
+// checkEditFlags validates the flags add, remove and fix-sections share.
+func checkEditFlags(cmd *cobra.Command) error {
+	if output := cmd.Flag("output").Value.String(); output != "text" && output != "json" {
+		return fmt.Errorf("invalid output format: %s", output)
+	}
+	if !cmd.Flag("select").Changed {
+		return errors.New("--select is required; use --select '*' for every repo")
+	}
+
+	return nil
+}
+
+// editRepos changes the manifest of every repo selected by the flags of cmd
+// with edit, which returns the result and why a repo without changes is
+// skipped, as app.EditRepos does. The inventory is saved if it changed, and
+// the outcome printed as with update --all.
+func editRepos(cmd *cobra.Command, edit func(inv *app.Inventory, target *app.RepoUpdate) (*app.UpdateResult, string, error)) error {
+	inv, err := workspace.LoadInventory()
+	if err != nil {
+		return err
+	}
+	opts, err := repoUpdateOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	patterns, _ := cmd.Flags().GetStringSlice("select")
+	targets, err := app.SelectRepos(inv, cmd.Flag("dir").Value.String(), patterns)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return errors.New("no repos selected")
+	}
+
+	changed := app.EditRepos(cmd.Context(), inv, targets, opts, func(target *app.RepoUpdate) (*app.UpdateResult, string, error) {
+		return edit(inv, target)
+	})
+	if changed {
+		err := workspace.SaveInventory(inv)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeUpdates(cmd, targets)
+}
+
+// addEditFlags adds the flags add, remove and fix-sections share to cmd.
+func addEditFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("select", nil, "Change repos matching this pattern; can be repeated")
+	cmd.Flags().String("dir", "", "Directory holding checkouts of the repos")
+	cmd.Flags().Bool("dry-run", false, "Print a diff of each manifest instead of writing it")
+	cmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	cmd.Flags().Bool("skip-lockfile", false, "Do not regenerate lockfiles after changing manifests")
+	cmd.Flags().String("registry", "", "npm registry to resolve packages against when regenerating lockfiles")
+	cmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "Number of repos to change at once")
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// addCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	addCmd.Flags().Bool("dev", false, "Add to devDependencies instead of dependencies")
+	addEditFlags(addCmd)
+}
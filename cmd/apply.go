@@ -0,0 +1,107 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan.json>",
+	Short: "Applies a plan written by unify --plan",
+	Long: `Applies a plan written by unify --plan, after it has been reviewed and
+perhaps edited. A plan only applies to the inventory it was made from; if
+parse has picked up changes since, apply refuses it and unify --plan has to
+be run again.
+
+With --manifests, the package.json of every repo the plan moves is updated
+as well, the same way update does. The inventory is only saved once every
+manifest has been updated.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		plan, err := app.DecodePlan(data)
+		if err != nil {
+			return err
+		}
+		inv, err := workspace.LoadInventory()
+		if err != nil {
+			return err
+		}
+
+		result, err := app.ApplyPlan(inv, plan, workspace.Config.Exclusions)
+		if err != nil {
+			return err
+		}
+		err = app.WriteMigrationText(os.Stdout, result)
+		if err != nil {
+			return err
+		}
+		if cmd.Flag("manifests").Changed {
+			err = applyManifests(cmd, result)
+			if err != nil {
+				return err
+			}
+		}
+
+		return workspace.SaveInventory(result.Inventory)
+	},
+}
+
+// applyManifests updates the package.json of every repo result moves, as
+// update does.
+func applyManifests(cmd *cobra.Command, result *app.UnifyResult) error {
+	npmrc, err := readNpmrc()
+	if err != nil {
+		return err
+	}
+	opts := app.RepoUpdateOptions{
+		Lockfile:   workspace.Config.Lockfile,
+		Update:     workspace.Config.Update,
+		Exclusions: workspace.Config.Exclusions,
+		Npmrc:      npmrc,
+	}
+	updated := make(map[string]bool)
+	for _, migration := range result.Migrations {
+		for _, repo := range migration.Repos {
+			if updated[repo] {
+				continue
+			}
+			updated[repo] = true
+
+			info := result.Inventory.Repos[repo]
+			if info.Source != app.SourceDir {
+				log.Printf("Skipping %s: only repos parsed from a directory can be updated\n", repo)
+				continue
+			}
+			target := &app.RepoUpdate{Repo: repo, Dir: info.Location}
+			err := app.UpdateRepo(cmd.Context(), result.Inventory, target, opts)
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %w", repo, err)
+			}
+			err = app.WriteChanges(os.Stdout, target.Result)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().Bool("manifests", false, "Also update the package.json of every repo the plan moves")
+}
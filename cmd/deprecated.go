@@ -0,0 +1,82 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// deprecatedCmd represents the deprecated command
+var deprecatedCmd = &cobra.Command{
+	Use:   "deprecated",
+	Short: "Lists the deprecated package versions the repos use",
+	Long: `Asks the registry about every package in the inventory and lists the
+versions in use that are marked deprecated, with the deprecation message, the
+repos using them and whether the latest version is deprecated too.
+Deprecations allowed in the config file are listed separately. With --fail,
+or fail in the config file, the command fails when any deprecation that is
+not allowed is found, so that CI can catch them, or when a package could
+not be checked. Without it, packages that could not be checked are only
+listed. Versions that match nothing published, such as git URLs, are listed
+as not checked and never fail the command. For example:
+
+pacman deprecated
+pacman deprecated --fail --output json > deprecated.json`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if output := cmd.Flag("output").Value.String(); output != "text" && output != "json" {
+			return fmt.Errorf("invalid output format: %s", output)
+		}
+		return cobra.NoArgs(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inv, err := workspace.LoadInventory()
+		if err != nil {
+			return err
+		}
+		client, err := registryClient()
+		if err != nil {
+			return err
+		}
+
+		report, err := app.Deprecated(cmd.Context(), inv, client, app.DeprecatedOptions{
+			RegistryQuery: registryQuery(cmd),
+			Allow:         workspace.Config.Deprecated.Allow,
+		})
+		if err != nil {
+			return err
+		}
+
+		if cmd.Flag("output").Value.String() == "json" {
+			err = app.WriteDeprecationsJSON(os.Stdout, report)
+		} else {
+			err = app.WriteDeprecationsText(os.Stdout, report)
+		}
+		if err != nil {
+			return err
+		}
+
+		return report.Check(workspace.Config.Deprecated.Fail || cmd.Flag("fail").Changed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deprecatedCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// deprecatedCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	deprecatedCmd.Flags().Bool("fail", false, "Fail when deprecated versions that are not allowed are in use")
+	deprecatedCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	addRegistryQueryFlags(deprecatedCmd)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <from> <to>",
+	Short: "Shows how dependencies changed between two inventory snapshots",
+	Long: `Every parse keeps a timestamped snapshot of the inventory. diff compares two
+of them and reports added and removed packages, version changes per repo and
+new or eliminated version variants.
+
+A snapshot is named by its timestamp (see --list), by "current" for the live
+state file, or by a path to a state file. For example:
+
+pacman diff 20220601100000 current
+pacman diff --output json 20220601100000 20220608100000`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flag("list").Changed {
+			return nil
+		}
+		if len(args) != 2 {
+			return errors.New("requires two snapshots to compare")
+		}
+		output := cmd.Flag("output").Value.String()
+		if output != "text" && output != "json" {
+			return fmt.Errorf("invalid output format: %s", output)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flag("list").Changed {
+			names, err := workspace.ListSnapshots()
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return nil
+		}
+
+		diff, err := workspace.Diff(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		if cmd.Flag("output").Value.String() == "json" {
+			return app.WriteDiffJSON(os.Stdout, diff)
+		}
+		return app.WriteDiffText(os.Stdout, diff)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	diffCmd.Flags().BoolP("list", "l", false, "List available snapshots")
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// fixSectionsCmd represents the fix-sections command
+var fixSectionsCmd = &cobra.Command{
+	Use:   "fix-sections --select <pattern>",
+	Short: "Moves misplaced dependencies between dependencies and devDependencies",
+	Long: `Moves every dependency of the repos matching --select that is in the wrong
+section, such as eslint in dependencies or a runtime library in
+devDependencies, to the right one. Where a dependency belongs is decided by
+the first of --rules with an opinion:
+
+config    the section set for the package in the config file
+imports   dependencies if the repo's source imports it, devDependencies if
+          only its tests and tooling do
+majority  the section more than half of the repos list it in
+
+The default is config, then majority. Each move is logged, shown in dry-run
+diffs and listed with its reason. Lockfiles are regenerated and the
+inventory refreshed, as with add. For example:
+
+pacman fix-sections --select '*' --dry-run
+pacman fix-sections --select 'payments-*' --rules config,imports,majority`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := checkEditFlags(cmd); err != nil {
+			return err
+		}
+		return cobra.NoArgs(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := sectionOptions(cmd)
+
+		return editRepos(cmd, func(inv *app.Inventory, target *app.RepoUpdate) (*app.UpdateResult, string, error) {
+			result, err := app.FixRepoSections(inv, target, opts)
+			return result, "no misplaced dependencies", err
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fixSectionsCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// fixSectionsCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	fixSectionsCmd.Flags().StringSlice("rules", nil, "Rules deciding sections, in order: config, imports, majority")
+	addEditFlags(fixSectionsCmd)
+}
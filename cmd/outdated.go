@@ -0,0 +1,111 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// outdatedCmd represents the outdated command
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "Shows how far behind the latest versions the repos are",
+	Long: `Asks the registry about every package in the inventory and shows, for each
+package and repo, the version the repo uses, the newest version its declared
+range allows and whether it is a major, minor or patch version behind the
+latest. Only repos that are behind are shown, unless --all is given;
+--severity minor or major hides smaller lags. Ignored packages and repos are
+left out. Packages that could not be checked are listed, and with --fail make
+the command fail, as with deprecated. For example:
+
+pacman outdated
+pacman outdated --package 'react*' --repo 'payments-*' --severity major
+pacman outdated --output csv > outdated.csv`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		output := cmd.Flag("output").Value.String()
+		if output != "table" && output != "json" && output != "csv" {
+			return fmt.Errorf("invalid output format: %s", output)
+		}
+		if cmd.Flag("all").Changed && cmd.Flag("severity").Changed {
+			return errors.New("--all and --severity cannot be used together")
+		}
+		return cobra.NoArgs(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inv, err := workspace.LoadInventory()
+		if err != nil {
+			return err
+		}
+		client, err := registryClient()
+		if err != nil {
+			return err
+		}
+
+		opts := app.OutdatedOptions{
+			RegistryQuery: registryQuery(cmd),
+			Severity:      cmd.Flag("severity").Value.String(),
+			All:           cmd.Flag("all").Changed,
+		}
+		report, err := app.Outdated(cmd.Context(), inv, client, opts)
+		if err != nil {
+			return err
+		}
+
+		switch cmd.Flag("output").Value.String() {
+		case "json":
+			err = app.WriteOutdatedJSON(os.Stdout, report)
+		case "csv":
+			err = app.WriteOutdatedCSV(os.Stdout, report)
+		default:
+			err = app.WriteOutdatedText(os.Stdout, report)
+		}
+		if err != nil {
+			return err
+		}
+
+		return report.Check(cmd.Flag("fail").Changed)
+	},
+}
+
+// registryQuery returns the packages and repos selected by the flags of
+// cmd, which addRegistryQueryFlags added.
+func registryQuery(cmd *cobra.Command) app.RegistryQuery {
+	query := app.RegistryQuery{Exclusions: workspace.Config.Exclusions}
+	query.Packages, _ = cmd.Flags().GetStringSlice("package")
+	query.Repos, _ = cmd.Flags().GetStringSlice("repo")
+	query.Jobs, _ = cmd.Flags().GetInt("jobs")
+
+	return query
+}
+
+// addRegistryQueryFlags adds the flags outdated and deprecated share to cmd.
+func addRegistryQueryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("package", nil, "Only show packages matching this pattern; can be repeated")
+	cmd.Flags().StringSlice("repo", nil, "Only show repos matching this pattern; can be repeated")
+	cmd.Flags().IntP("jobs", "j", 8, "Number of packages to fetch at once")
+}
+
+func init() {
+	rootCmd.AddCommand(outdatedCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// outdatedCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	outdatedCmd.Flags().String("severity", app.LagPatch, "Only show repos at least this far behind: patch, minor or major")
+	outdatedCmd.Flags().Bool("all", false, "Also show repos that are up to date")
+	outdatedCmd.Flags().Bool("fail", false, "Fail when a package could not be checked")
+	outdatedCmd.Flags().StringP("output", "o", "table", "Output format: table, json or csv")
+	addRegistryQueryFlags(outdatedCmd)
+}
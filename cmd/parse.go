@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/kirupakaran/pacman/app"
 	"github.com/spf13/cobra"
@@ -36,12 +37,29 @@ to quickly create a Cobra application.`,
 			return fmt.Errorf("either repo list or directory path required")
 		}
 	},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		var src app.Source
 		if cmd.Flag("dir").Changed {
-			app.Parse(cmd.Flag("dirPath").Value.String())
+			src = app.NewDirSource(cmd.Flag("dirPath").Value.String())
 		} else if cmd.Flag("repos").Changed {
-			app.ParseByRepo(cmd.Flag("repoList").Value.String())
+			repos, err := app.ReadRepoList(cmd.Flag("repoList").Value.String())
+			if err != nil {
+				return err
+			}
+			src = &app.GitHubSource{Client: app.NewGitHubClient(ctx, os.Getenv("GITHUB_PAT")), Repos: repos}
+		}
+
+		inv, err := app.BuildInventory(ctx, src)
+		if err != nil {
+			return err
+		}
+		err = workspace.SaveInventory(inv)
+		if err != nil {
+			return err
 		}
+		return workspace.SaveSnapshot(inv)
 	},
 }
 
@@ -0,0 +1,55 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"errors"
+
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// removeCmd represents the remove command
+var removeCmd = &cobra.Command{
+	Use:   "remove <package> --select <pattern>",
+	Short: "Removes a dependency from the package.json of the selected repos",
+	Long: `Removes a dependency from the dependencies and devDependencies of every
+repo matching --select, such as a package that is no longer allowed. Repos
+that do not depend on it are skipped. The lockfile of each changed repo is
+regenerated, as with update, and the inventory is refreshed. For example:
+
+pacman remove request --select '*'`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := checkEditFlags(cmd); err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return errors.New("requires a package as an argument")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		return editRepos(cmd, func(inv *app.Inventory, target *app.RepoUpdate) (*app.UpdateResult, string, error) {
+			result, err := app.RemoveDependency(target.Repo, target.Before, name)
+			return result, "does not depend on " + name, err
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(removeCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// removeCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	addEditFlags(removeCmd)
+}
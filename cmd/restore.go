@@ -0,0 +1,61 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [timestamp]",
+	Short: "Restores state and outputs from a backup. Lists available backups when no timestamp is given.",
+	Long: `parse and unify back up pacman-state.json, package.json, packages_list.json
+and aliases.json before changing them. restore puts the files of a backup back
+in place, and removes those of them the backup does not have, after backing up
+the current files so the restore can itself be undone. Without a timestamp,
+the available backups are listed.
+
+Use "latest" to restore the most recent backup.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backups, err := workspace.ListBackups()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 0 {
+			if len(backups) == 0 {
+				fmt.Println("No backups available")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "BACKUP\tTAKEN\tFILES")
+			for _, backup := range backups {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", backup.Name, backup.Time.Format("2006-01-02 15:04:05"),
+					strings.Join(backup.Files, ", "))
+			}
+			return w.Flush()
+		}
+
+		name := args[0]
+		if name == "latest" {
+			if len(backups) == 0 {
+				return fmt.Errorf("no backups available")
+			}
+			name = backups[len(backups)-1].Name
+		}
+		return workspace.Restore(name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
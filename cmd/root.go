@@ -7,9 +7,16 @@ package cmd
 import (
 	"os"
 
+	"github.com/kirupakaran/pacman/app"
 	"github.com/spf13/cobra"
 )
 
+// workspace is where commands read and write pacman's state and outputs.
+var workspace *app.Workspace
+
+// releaseLock releases the workdir lock taken before running a command.
+var releaseLock = func() {}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "pacman",
@@ -23,12 +30,54 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Name() == "help" || cmd.Name() == "completion" {
+			return nil
+		}
+		return setupWorkdir(cmd)
+	},
+}
+
+// setupWorkdir points pacman at the configured workdir and locks it for the
+// duration of the command.
+func setupWorkdir(cmd *cobra.Command) error {
+	// Arguments have been validated by now, so any error from here on is not
+	// a usage problem.
+	cmd.SilenceUsage = true
+
+	configPath := cmd.Flag("config").Value.String()
+	config, err := app.LoadConfig(configPath, cmd.Flag("config").Changed)
+	if err != nil {
+		return err
+	}
+
+	workdir := config.Workdir
+	if cmd.Flag("workdir").Changed || workdir == "" {
+		workdir = cmd.Flag("workdir").Value.String()
+	}
+	workspace, err = app.OpenWorkspace(workdir, config)
+	if err != nil {
+		return err
+	}
+
+	wait, err := cmd.Flags().GetDuration("wait")
+	if err != nil {
+		return err
+	}
+	release, err := workspace.Lock(wait)
+	if err != nil {
+		return err
+	}
+	releaseLock = release
+
+	return nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
+	releaseLock()
 	if err != nil {
 		os.Exit(1)
 	}
@@ -39,7 +88,9 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pacman.yaml)")
+	rootCmd.PersistentFlags().String("config", app.DefaultConfigFile, "Config file")
+	rootCmd.PersistentFlags().String("workdir", ".", "Directory where state and outputs are kept")
+	rootCmd.PersistentFlags().Duration("wait", 0, "How long to wait for another pacman run to release the workdir lock")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
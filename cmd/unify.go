@@ -1,10 +1,16 @@
 /*
 Copyright © 2022 NAME HERE <EMAIL ADDRESS>
-
 */
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
 	"github.com/kirupakaran/pacman/app"
 	"github.com/spf13/cobra"
 )
@@ -19,11 +25,105 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		app.Unify(cmd.Flag("minor").Changed)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inv, err := workspace.LoadInventory()
+		if err != nil {
+			return err
+		}
+		strategy := workspace.Config.Unify.Strategy
+		if cmd.Flag("strategy").Changed {
+			strategy = cmd.Flag("strategy").Value.String()
+		} else if cmd.Flag("minor").Changed {
+			strategy = app.StrategyMinor
+		}
+
+		opts := app.UnifyOptions{
+			Strategy:   strategy,
+			Policies:   workspace.Config.Unify.Packages,
+			Exclusions: workspace.Config.Exclusions,
+			AllowPrerelease: workspace.Config.Unify.AllowPrerelease ||
+				cmd.Flag("allow-prerelease").Changed,
+		}
+		// intersect and lowest-common pick from the published versions
+		if opts.UsesPublishedVersions() && !cmd.Flag("in-use").Changed {
+			opts.Versions, err = registryClient()
+			if err != nil {
+				return err
+			}
+		}
+
+		result, err := app.Unify(cmd.Context(), inv, opts)
+		if err != nil {
+			return err
+		}
+		printConflicts(result.Conflicts)
+
+		if plan := cmd.Flag("plan").Value.String(); plan != "" {
+			err = app.WriteFileAtomic(plan, app.EncodePlan(app.NewPlan(inv, result)), 0666)
+			if err != nil {
+				return fmt.Errorf("failed to write plan: %w", err)
+			}
+			fmt.Printf("Wrote plan to %s; review it and run pacman apply %s\n", plan, plan)
+			return nil
+		}
+
+		err = app.WriteMigrationText(os.Stdout, result)
+		if err != nil {
+			return err
+		}
+		if report := cmd.Flag("report").Value.String(); report != "" {
+			var buf bytes.Buffer
+			err = app.WriteMigrationJSON(&buf, result)
+			if err != nil {
+				return err
+			}
+			err = app.WriteFileAtomic(report, buf.Bytes(), 0666)
+			if err != nil {
+				return fmt.Errorf("failed to write migration report: %w", err)
+			}
+		}
+
+		return workspace.SaveInventory(result.Inventory)
 	},
 }
 
+// readNpmrc reads the user's .npmrc and the one in the current directory,
+// the same way npm does.
+func readNpmrc() (app.Npmrc, error) {
+	home, _ := os.UserHomeDir()
+
+	return app.ReadNpmrc(filepath.Join(home, ".npmrc"), ".npmrc")
+}
+
+// registryClient returns a client for the registries configured in the
+// .npmrc files readNpmrc reads.
+func registryClient() (*app.RegistryClient, error) {
+	npmrc, err := readNpmrc()
+	if err != nil {
+		return nil, err
+	}
+
+	return workspace.RegistryClient(npmrc), nil
+}
+
+// printConflicts lists the packages unify could not unify, with the range
+// each repo declares.
+func printConflicts(conflicts []app.Conflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Could not unify:")
+	for _, conflict := range conflicts {
+		fmt.Fprintf(w, "\n%s: %s\n", conflict.Package, conflict.Reason)
+		for _, r := range conflict.Ranges {
+			fmt.Fprintf(w, "  %s\t%s\t(uses %s)\n", r.Repo, r.Range, r.Version)
+		}
+	}
+	w.Flush()
+}
+
 func init() {
 	rootCmd.AddCommand(unifyCmd)
 
@@ -36,4 +136,11 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	unifyCmd.Flags().BoolP("minor", "", false, "Unifies packages based on minor version")
+	unifyCmd.Flags().StringP("strategy", "s", app.StrategyPatch,
+		"Strategy for packages without a policy in the config file: "+strings.Join(app.Strategies(), ", "))
+	unifyCmd.MarkFlagsMutuallyExclusive("minor", "strategy")
+	unifyCmd.Flags().Bool("allow-prerelease", false, "Let stable repos move onto prereleases, and prereleases across channels")
+	unifyCmd.Flags().Bool("in-use", false, "Only consider the versions in use for intersect and lowest-common, without asking the registry")
+	unifyCmd.Flags().String("plan", "", "Write the unification to this plan file for review instead of applying it")
+	unifyCmd.Flags().String("report", "", "Also write the migration report as JSON to this file")
 }
@@ -7,6 +7,9 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 
 	"github.com/kirupakaran/pacman/app"
 	"github.com/spf13/cobra"
@@ -14,15 +17,66 @@ import (
 
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Updates package.json in the given repo",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:   "update [repo path | --remote owner/name...]",
+	Short: "Updates package.json in the given repo, or in every repo with --all",
+	Long: `Sets the dependencies in a repo's package.json to the versions the
+inventory has for it. The repo is found in the inventory by the directory it
+was parsed from, or else by the directory name.
+
+With --all, every repo found during parse is updated, in parallel. Repos
+parsed from a directory are updated where they were parsed; --dir gives a
+directory holding checkouts of the repos instead, which is needed for repos
+read from GitHub. --repo limits the update to repos matching a pattern.
+Results are printed per repo, followed by a summary table. With
+--fix-sections, misplaced dependencies are moved between sections too, as
+fix-sections does.
+
+With --commit, each repo is updated on a new branch created from --base,
+and the changes are committed there; --push pushes the branch too. Repos
+with uncommitted changes are refused.
+
+With --remote, repos read from GitHub are updated without a checkout: the
+new package.json and lockfile are committed to a branch and proposed in a
+pull request, or in the open pull request from an earlier run. GITHUB_PAT
+must be set. For example:
+
+pacman update ../npm/wubwub
+pacman update --all
+pacman update --all --dir ~/src --repo 'payments-*'
+pacman update --all --dir ~/src --push --base origin/main
+pacman update --remote kirupakaran/wubwub
+pacman update --remote --all --dry-run`,
 	Args: func(cmd *cobra.Command, args []string) error {
+		if output := cmd.Flag("output").Value.String(); output != "text" && output != "json" {
+			return fmt.Errorf("invalid output format: %s", output)
+		}
+		if cmd.Flag("remote").Changed {
+			if cmd.Flag("dir").Changed {
+				return errors.New("--dir cannot be used with --remote")
+			}
+			if cmd.Flag("commit").Changed || cmd.Flag("push").Changed || cmd.Flag("base").Changed {
+				return errors.New("--commit, --push and --base cannot be used with --remote")
+			}
+			if cmd.Flag("all").Changed == (len(args) > 0) {
+				return errors.New("--remote requires repos as owner/name arguments, or --all")
+			}
+			return nil
+		}
+		if cmd.Flag("rules").Changed && !cmd.Flag("fix-sections").Changed {
+			return errors.New("--rules needs --fix-sections")
+		}
+		if cmd.Flag("base").Changed && !cmd.Flag("commit").Changed && !cmd.Flag("push").Changed {
+			return errors.New("--base needs --commit or --push")
+		}
+		if cmd.Flag("all").Changed {
+			if len(args) > 0 {
+				return errors.New("--all does not take a directory")
+			}
+			return nil
+		}
+		if cmd.Flag("dir").Changed || cmd.Flag("repo").Changed {
+			return errors.New("--dir and --repo need --all")
+		}
 		if len(args) < 1 {
 			return errors.New("requires a directory as an argument")
 		}
@@ -31,11 +85,136 @@ to quickly create a Cobra application.`,
 		}
 		return fmt.Errorf("invalid directory: %s", args[0])
 	},
-	Run: func(cmd *cobra.Command, args []string) {
-		app.Update(args[0])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inv, err := workspace.LoadInventory()
+		if err != nil {
+			return err
+		}
+
+		opts, err := repoUpdateOptions(cmd)
+		if err != nil {
+			return err
+		}
+		remote := cmd.Flag("remote").Changed
+		if remote {
+			opts.Client = app.NewGitHubClient(cmd.Context(), os.Getenv("GITHUB_PAT"))
+		}
+		if cmd.Flag("fix-sections").Changed {
+			sections := sectionOptions(cmd)
+			opts.Sections = &sections
+		}
+		if cmd.Flag("commit").Changed || cmd.Flag("push").Changed {
+			git := workspace.Config.Git
+			if cmd.Flag("push").Changed {
+				git.Push = true
+			}
+			if cmd.Flag("base").Changed {
+				git.Base = cmd.Flag("base").Value.String()
+			}
+			opts.Git = &git
+		}
+
+		if !cmd.Flag("all").Changed && !remote {
+			target := &app.RepoUpdate{Dir: args[0]}
+			repo, found := inv.RepoForDir(target.Dir)
+			if !found {
+				repo = filepath.Base(target.Dir)
+			}
+			target.Repo = repo
+			target.Err = app.UpdateRepo(cmd.Context(), inv, target, opts)
+			if cmd.Flag("output").Value.String() == "json" {
+				err := app.WriteUpdatesJSON(os.Stdout, []*app.RepoUpdate{target})
+				if err == nil {
+					err = target.Err
+				}
+				return err
+			}
+			if target.Err != nil {
+				return target.Err
+			}
+			return app.WriteUpdate(os.Stdout, target, opts.DryRun)
+		}
+
+		patterns, _ := cmd.Flags().GetStringSlice("repo")
+		var targets []*app.RepoUpdate
+		switch {
+		case remote && len(args) > 0:
+			for _, repo := range args {
+				targets = append(targets, &app.RepoUpdate{Repo: repo})
+			}
+		case remote:
+			targets, err = app.SelectRemoteRepos(inv, patterns)
+		default:
+			targets, err = app.SelectRepos(inv, cmd.Flag("dir").Value.String(), patterns)
+		}
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return errors.New("no repos selected")
+		}
+
+		app.UpdateRepos(cmd.Context(), inv, targets, opts)
+		return writeUpdates(cmd, targets)
 	},
 }
 
+// repoUpdateOptions returns the options the config, the .npmrc files
+// readNpmrc reads and the flags update, add, remove and fix-sections share
+// set for changing repos.
+func repoUpdateOptions(cmd *cobra.Command) (app.RepoUpdateOptions, error) {
+	npmrc, err := readNpmrc()
+	if err != nil {
+		return app.RepoUpdateOptions{}, err
+	}
+
+	opts := app.RepoUpdateOptions{
+		DryRun:     cmd.Flag("dry-run").Changed,
+		Lockfile:   workspace.Config.Lockfile,
+		Update:     workspace.Config.Update,
+		Exclusions: workspace.Config.Exclusions,
+		Npmrc:      npmrc,
+		Remote:     workspace.Config.Remote,
+	}
+	if cmd.Flag("skip-lockfile").Changed {
+		opts.Lockfile.Skip = true
+	}
+	if cmd.Flag("registry").Changed {
+		opts.Lockfile.Registry = cmd.Flag("registry").Value.String()
+	}
+	opts.Jobs, _ = cmd.Flags().GetInt("jobs")
+
+	return opts, nil
+}
+
+// writeUpdates prints the outcome of targets in the format the --output
+// flag of cmd asks for, and returns an error if any of them failed.
+func writeUpdates(cmd *cobra.Command, targets []*app.RepoUpdate) error {
+	var err error
+	if cmd.Flag("output").Value.String() == "json" {
+		err = app.WriteUpdatesJSON(os.Stdout, targets)
+	} else {
+		err = app.WriteUpdatesText(os.Stdout, targets, cmd.Flag("dry-run").Changed)
+	}
+	if err != nil {
+		return err
+	}
+
+	return app.UpdateFailures(targets)
+}
+
+// sectionOptions returns the options the config and the --rules flag of cmd
+// set for moving dependencies between sections.
+func sectionOptions(cmd *cobra.Command) app.SectionOptions {
+	opts := workspace.Config.Sections.SectionOptions()
+	opts.Exclusions = workspace.Config.Exclusions
+	if cmd.Flag("rules").Changed {
+		opts.Rules, _ = cmd.Flags().GetStringSlice("rules")
+	}
+
+	return opts
+}
+
 func init() {
 	rootCmd.AddCommand(updateCmd)
 
@@ -47,5 +226,18 @@ func init() {
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
-	// updateCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	updateCmd.Flags().Bool("all", false, "Update every repo found during parse, or with --remote every repo read from GitHub")
+	updateCmd.Flags().Bool("remote", false, "Open pull requests on GitHub instead of updating checkouts; must set GITHUB_PAT env")
+	updateCmd.Flags().Bool("fix-sections", false, "Also move misplaced dependencies between dependencies and devDependencies; see fix-sections")
+	updateCmd.Flags().StringSlice("rules", nil, "Rules deciding sections for --fix-sections, in order: config, imports, majority")
+	updateCmd.Flags().Bool("commit", false, "Commit the changes in each repo on a new branch; refuses repos with uncommitted changes")
+	updateCmd.Flags().Bool("push", false, "Push the branch after committing; implies --commit")
+	updateCmd.Flags().String("base", "", "Revision to create branches from, for --commit (default the commit checked out)")
+	updateCmd.Flags().String("dir", "", "Directory holding checkouts of the repos, for --all")
+	updateCmd.Flags().StringSlice("repo", nil, "Only update repos matching this pattern, for --all; can be repeated")
+	updateCmd.Flags().Bool("dry-run", false, "Print a diff of each manifest instead of writing it")
+	updateCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	updateCmd.Flags().Bool("skip-lockfile", false, "Do not regenerate lockfiles after updating manifests")
+	updateCmd.Flags().String("registry", "", "npm registry to resolve packages against when regenerating lockfiles")
+	updateCmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "Number of repos to update at once, for --all")
 }
@@ -0,0 +1,49 @@
+/*
+Copyright © 2022 NAME HERE <EMAIL ADDRESS>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kirupakaran/pacman/app"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate [package.json]",
+	Short: "Checks that the aggregate package.json is a manifest npm would accept",
+	Long: `Checks the name, version, engines and every dependency key and spec of the
+aggregate package.json written by parse and unify, or of the given file. Each
+problem is printed and the command fails if there are any, so it can be used
+as a CI step.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := filepath.Join(workspace.Dir, "package.json")
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		problems := app.ValidateManifest(data)
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("found %d problem(s)", len(problems))
+		}
+		fmt.Println("package.json is valid")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}